@@ -0,0 +1,135 @@
+package beater
+
+import (
+	"context"
+	"database/sql/driver"
+	"fmt"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/rds/rdsutils"
+	"github.com/elastic/beats/libbeat/logp"
+	"github.com/go-sql-driver/mysql"
+)
+
+// RDS auth tokens are valid for 15 minutes, they're refreshed earlier so new connections never get an expired token
+const authTokenRefresh = 10 * time.Minute
+
+// authToken holds the current RDS IAM auth token, it's safe for concurrent use as the pools open their
+// connections with it
+type authToken struct {
+	mutex sync.Mutex
+	key   string
+	token string
+	time  time.Time
+}
+
+// get returns the current token
+func (at *authToken) get() string {
+	at.mutex.Lock()
+	defer at.mutex.Unlock()
+
+	return at.token
+}
+
+// expired returns whether the token is about to expire or was generated for another user, host or region
+func (at *authToken) expired(key string) bool {
+	at.mutex.Lock()
+	defer at.mutex.Unlock()
+
+	return key != at.key || time.Since(at.time) >= authTokenRefresh
+}
+
+// set replaces the token
+func (at *authToken) set(key string, token string) {
+	at.mutex.Lock()
+	defer at.mutex.Unlock()
+
+	at.key = key
+	at.token = token
+	at.time = time.Now()
+}
+
+// refreshAuthToken generates a fresh RDS IAM auth token when AuthMode is awsiam and the token is about to expire
+// or was generated for another user, host or region. The token isn't part of the connection string, so a refresh
+// doesn't reconnect: the open connections stay authenticated and the new ones are opened with the fresh token
+func (bt *Sqlbeat) refreshAuthToken() error {
+	if bt.authMode != authModeAWSIAM {
+		return nil
+	}
+
+	endpoint := fmt.Sprintf("%v:%v", bt.hostname, bt.port)
+	key := fmt.Sprintf("%v@%v/%v", bt.username, endpoint, bt.awsRegion)
+	if !bt.authToken.expired(key) {
+		return nil
+	}
+
+	// The credentials are taken from the environment, the shared credentials file or the instance role
+	sess, err := session.NewSession()
+	if err != nil {
+		return fmt.Errorf("Error loading the AWS credentials: %v", err)
+	}
+
+	token, err := rdsutils.BuildAuthToken(endpoint, bt.awsRegion, bt.username, sess.Config.Credentials)
+	if err != nil {
+		return fmt.Errorf("Error generating an RDS auth token for %v: %v", endpoint, err)
+	}
+
+	logp.Debug("sqlbeat", "Generated an RDS auth token for %v@%v", bt.username, endpoint)
+	bt.authToken.set(key, token)
+	return nil
+}
+
+// authTokenConnector opens every new connection of a pool with the current RDS IAM auth token as its password,
+// the connection string of the pool has no password
+type authTokenConnector struct {
+	driver     driver.Driver
+	dbType     string
+	connString string
+	token      *authToken
+}
+
+// Connect opens a connection with the current token
+func (ac *authTokenConnector) Connect(ctx context.Context) (driver.Conn, error) {
+	connString, err := authTokenConnString(ac.dbType, ac.connString, ac.token.get())
+	if err != nil {
+		return nil, err
+	}
+
+	if driverContext, ok := ac.driver.(driver.DriverContext); ok {
+		connector, err := driverContext.OpenConnector(connString)
+		if err != nil {
+			return nil, err
+		}
+		return connector.Connect(ctx)
+	}
+	return ac.driver.Open(connString)
+}
+
+// Driver returns the driver of the connector
+func (ac *authTokenConnector) Driver() driver.Driver {
+	return ac.driver
+}
+
+// authTokenConnString sets the password of a mysql or postgres connection string to the auth token
+func authTokenConnString(dbType string, connString string, token string) (string, error) {
+	switch dbType {
+	case dbtMySQL:
+		mysqlConfig, err := mysql.ParseDSN(connString)
+		if err != nil {
+			return "", err
+		}
+		mysqlConfig.Passwd = token
+		return mysqlConfig.FormatDSN(), nil
+	case dbtPSQL:
+		psqlURL, err := url.Parse(connString)
+		if err != nil {
+			return "", err
+		}
+		psqlURL.User = url.UserPassword(psqlURL.User.Username(), token)
+		return psqlURL.String(), nil
+	}
+	return "", fmt.Errorf("AuthMode %v isn't supported with DB type %v", authModeAWSIAM, dbType)
+}
@@ -0,0 +1,110 @@
+package beater
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+
+	"github.com/adibendahan/sqlbeat/config"
+	"gopkg.in/yaml.v2"
+)
+
+// loadQueryCatalog reads a JSON/YAML query catalog file and appends its queries to the configured queries
+func loadQueryCatalog(cfg *config.SqlbeatConfig, path string) error {
+
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("Error reading query catalog: %v", err)
+	}
+
+	// JSON catalogs are parsed as JSON, anything else as YAML
+	var entries []config.QueryCatalogEntry
+	if strings.ToLower(filepath.Ext(path)) == ".json" {
+		err = json.Unmarshal(data, &entries)
+	} else {
+		err = yaml.Unmarshal(data, &entries)
+	}
+	if err != nil {
+		return fmt.Errorf("Error parsing query catalog %v: %v", path, err)
+	}
+
+	// Every entry must be fully specified
+	for index, entry := range entries {
+		if entry.Query == "" {
+			return fmt.Errorf("Query catalog %v entry #%d has no query", path, index)
+		}
+		if entry.Type == "" {
+			return fmt.Errorf("Query catalog %v entry #%d has no type", path, index)
+		}
+	}
+
+	// Pad the per-query arrays so every catalog entry lands on the same index as its query
+	queriesCount := len(cfg.Queries)
+	cfg.QueryNullDefaults = padNullDefaults(cfg.QueryNullDefaults, queriesCount)
+	cfg.QueryTimeouts = padStrings(cfg.QueryTimeouts, queriesCount)
+	cfg.QueryIncludeColumns = padStringLists(cfg.QueryIncludeColumns, queriesCount)
+	cfg.QueryExcludeColumns = padStringLists(cfg.QueryExcludeColumns, queriesCount)
+	cfg.QueryExpectedColumns = padStringLists(cfg.QueryExpectedColumns, queriesCount)
+	cfg.QueryPools = padStrings(cfg.QueryPools, queriesCount)
+	cfg.QueryNames = padStrings(cfg.QueryNames, queriesCount)
+	cfg.QueryEventTypes = padStrings(cfg.QueryEventTypes, queriesCount)
+	cfg.QueryDatabases = padStrings(cfg.QueryDatabases, queriesCount)
+	cfg.QueryKeyColumns = padStrings(cfg.QueryKeyColumns, queriesCount)
+	cfg.QueryDeltaWildcards = padStrings(cfg.QueryDeltaWildcards, queriesCount)
+	cfg.QueryTargets = padStringLists(cfg.QueryTargets, queriesCount)
+	cfg.PublishOnChangeOnly = padBools(cfg.PublishOnChangeOnly, queriesCount)
+
+	for _, entry := range entries {
+		cfg.Queries = append(cfg.Queries, entry.Query)
+		cfg.QueryTypes = append(cfg.QueryTypes, entry.Type)
+		cfg.QueryNullDefaults = append(cfg.QueryNullDefaults, entry.NullDefaults)
+		cfg.QueryTimeouts = append(cfg.QueryTimeouts, entry.Timeout)
+		cfg.QueryIncludeColumns = append(cfg.QueryIncludeColumns, entry.IncludeColumns)
+		cfg.QueryExcludeColumns = append(cfg.QueryExcludeColumns, entry.ExcludeColumns)
+		cfg.QueryExpectedColumns = append(cfg.QueryExpectedColumns, entry.ExpectedColumns)
+		cfg.QueryPools = append(cfg.QueryPools, entry.Pool)
+		cfg.QueryNames = append(cfg.QueryNames, entry.Name)
+		cfg.QueryEventTypes = append(cfg.QueryEventTypes, entry.EventType)
+		cfg.QueryDatabases = append(cfg.QueryDatabases, entry.Database)
+		cfg.QueryKeyColumns = append(cfg.QueryKeyColumns, entry.KeyColumn)
+		cfg.QueryDeltaWildcards = append(cfg.QueryDeltaWildcards, entry.DeltaWildcard)
+		cfg.QueryTargets = append(cfg.QueryTargets, entry.Targets)
+		cfg.PublishOnChangeOnly = append(cfg.PublishOnChangeOnly, entry.PublishOnChangeOnly)
+	}
+
+	return nil
+}
+
+// padStrings pads a per-query array with empty entries up to count entries
+func padStrings(values []string, count int) []string {
+	for len(values) < count {
+		values = append(values, "")
+	}
+	return values
+}
+
+// padStringLists pads a per-query array of lists with empty lists up to count entries
+func padStringLists(values [][]string, count int) [][]string {
+	for len(values) < count {
+		values = append(values, nil)
+	}
+	return values
+}
+
+// padNullDefaults pads the per-query null defaults with empty entries up to count entries
+func padNullDefaults(values []map[string]interface{}, count int) []map[string]interface{} {
+	for len(values) < count {
+		values = append(values, nil)
+	}
+	return values
+}
+
+// padBools pads a per-query array of flags with false entries up to count entries
+func padBools(values []bool, count int) []bool {
+	for len(values) < count {
+		values = append(values, false)
+	}
+	return values
+}
@@ -0,0 +1,264 @@
+package beater
+
+import (
+	"database/sql"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/elastic/beats/libbeat/logp"
+)
+
+// declaredColumnTypes returns the column type of each column from the type declared by the driver,
+// columns of an unknown type are inferred from their value
+func declaredColumnTypes(columnTypes []*sql.ColumnType) []int {
+	declaredTypes := make([]int, len(columnTypes))
+	for i, columnType := range columnTypes {
+		declaredTypes[i] = declaredColumnType(columnType.DatabaseTypeName())
+	}
+	return declaredTypes
+}
+
+// declaredColumnType maps a DB type name (e.g. VARCHAR, BIGINT, DECIMAL) to a column type
+func declaredColumnType(databaseTypeName string) int {
+	name := strings.ToUpper(databaseTypeName)
+
+	switch name {
+	case "":
+		// The driver doesn't know the type
+		return columnTypeAuto
+	case "TINYINT", "SMALLINT", "MEDIUMINT", "INT", "INTEGER", "BIGINT", "YEAR",
+		"INT2", "INT4", "INT8", "INT16", "INT32", "INT64", "SERIAL", "BIGSERIAL":
+		return columnTypeInt
+	case "FLOAT", "DOUBLE", "DOUBLE PRECISION", "REAL", "FLOAT4", "FLOAT8", "FLOAT32", "FLOAT64",
+		"DECIMAL", "NUMERIC":
+		return columnTypeFloat
+	case "MONEY", "SMALLMONEY":
+		// go-mssqldb reads MONEY and SMALLMONEY as plain numbers with 4 decimals (e.g. -0.0050), views may format
+		// them as currency (e.g. $1,234.56)
+		return columnTypeMoney
+	case "BOOL", "BOOLEAN", "BIT":
+		return columnTypeBool
+	}
+
+	switch {
+	case strings.HasPrefix(name, "UNSIGNED "), strings.HasPrefix(name, "UINT"):
+		return columnTypeUint
+	case strings.HasPrefix(name, "DECIMAL"), strings.HasPrefix(name, "NUMERIC"):
+		return columnTypeFloat
+	}
+
+	// Text, time and any other type is kept as is
+	return columnTypeString
+}
+
+// columnTypeAt returns the column type of the column on the given index, when there are no declared
+// column types the column type is inferred from the value
+func columnTypeAt(columnTypes []int, index int) int {
+	if index < len(columnTypes) {
+		return columnTypes[index]
+	}
+	return columnTypeAuto
+}
+
+// columnType returns the column type of a column, the ColumnTypes of the config take precedence over the
+// declared column type. Without type inference the other columns are strings
+func (bt *Sqlbeat) columnType(strColName string, declaredType int) int {
+	if columnType, ok := bt.columnTypeOverrides[strColName]; ok {
+		return columnType
+	}
+	if bt.disableTypeInference {
+		return columnTypeString
+	}
+	return declaredType
+}
+
+// checkDeltaInference returns an error for delta columns when type inference is disabled, their delta can't be
+// calculated without parsing their value as a number (cumulative mode reports the raw value). Delta columns typed
+// in ColumnTypes are still parsed
+func (bt *Sqlbeat) checkDeltaInference(strColName string, isDelta bool) error {
+	if !isDelta || !bt.disableTypeInference || bt.deltaOutputMode == deltaOutputModeCumulative {
+		return nil
+	}
+	if columnType, ok := bt.columnTypeOverrides[strColName]; ok && columnType != columnTypeString && columnType != columnTypeBool {
+		return nil
+	}
+	return fmt.Errorf("Delta column '%v' must be parsed as a number, which DisableTypeInference disables (type it in ColumnTypes)", strColName)
+}
+
+// parseTypedColumnValue parses a column value as its column type, falling back to inferring
+// the type from the value when it can't be parsed as its column type
+func parseTypedColumnValue(strColValue string, columnType int) (int, int64, uint64, float64) {
+	switch columnType {
+	case columnTypeString:
+		return columnTypeString, 0, 0, 0
+	case columnTypeInt:
+		if nColValue, err := strconv.ParseInt(strColValue, 10, 64); err == nil {
+			return columnTypeInt, nColValue, 0, 0
+		}
+	case columnTypeUint:
+		if uColValue, err := strconv.ParseUint(strColValue, 10, 64); err == nil {
+			return columnTypeUint, 0, uColValue, 0
+		}
+	case columnTypeFloat:
+		if fColValue, err := strconv.ParseFloat(strColValue, 64); err == nil {
+			return columnTypeFloat, 0, 0, fColValue
+		}
+	}
+
+	return parseColumnValue(strColValue)
+}
+
+// columnsDrift returns the expected columns missing from the columns and the columns that aren't expected
+func columnsDrift(expected []string, columns []string) (missing []string, unexpected []string) {
+	returned := make(map[string]bool)
+	for _, strColName := range columns {
+		returned[strColName] = true
+	}
+
+	expectedColumns := make(map[string]bool)
+	for _, strColName := range expected {
+		expectedColumns[strColName] = true
+		if !returned[strColName] {
+			missing = append(missing, strColName)
+		}
+	}
+
+	for _, strColName := range columns {
+		if !expectedColumns[strColName] {
+			unexpected = append(unexpected, strColName)
+		}
+	}
+
+	return missing, unexpected
+}
+
+var (
+	// the locale formatted numbers of each NumberFormat, with group separators and an optional fraction
+	numberFormatRegexps = map[string]*regexp.Regexp{
+		numberFormatCommaPeriod: regexp.MustCompile(`^[-+]?[0-9]{1,3}(,[0-9]{3})*(\.[0-9]+)?$`),
+		numberFormatPeriodComma: regexp.MustCompile(`^[-+]?[0-9]{1,3}(\.[0-9]{3})*(,[0-9]+)?$`),
+		numberFormatSpaceComma:  regexp.MustCompile(`^[-+]?[0-9]{1,3}( [0-9]{3})*(,[0-9]+)?$`),
+	}
+
+	// the group and decimal separators of each NumberFormat
+	numberFormatSeparators = map[string][2]string{
+		numberFormatCommaPeriod: {",", "."},
+		numberFormatPeriodComma: {".", ","},
+		numberFormatSpaceComma:  {" ", ","},
+	}
+)
+
+// normalizeNumber converts a number in the given NumberFormat to a plain number (1234.5), ok is false when
+// the value isn't a number in that format or the format is strict
+func normalizeNumber(strColValue string, numberFormat string) (string, bool) {
+	formatRegexp, ok := numberFormatRegexps[numberFormat]
+	if !ok || !formatRegexp.MatchString(strColValue) {
+		return strColValue, false
+	}
+
+	separators := numberFormatSeparators[numberFormat]
+	normalized := strings.Replace(strColValue, separators[0], "", -1)
+	normalized = strings.Replace(normalized, separators[1], ".", -1)
+	return normalized, true
+}
+
+// isPlainNumber returns whether a value parses as a number without a NumberFormat
+func isPlainNumber(strColValue string) bool {
+	_, err := strconv.ParseFloat(strColValue, 64)
+	return err == nil
+}
+
+// normalizeMoney converts a money value formatted as currency, with a currency symbol, group separators and a
+// leading minus or accounting parentheses (e.g. $1,234.5600, -1.234,56 € or (1,234.56)), to a plain number. The
+// groups follow the NumberFormat, 1,234.5 when it's strict. Plain numbers and other values are kept as is
+func normalizeMoney(strColValue string, numberFormat string) string {
+	if isPlainNumber(strColValue) {
+		return strColValue
+	}
+
+	value := strings.TrimSpace(strColValue)
+	negative := false
+	if strings.HasPrefix(value, "(") && strings.HasSuffix(value, ")") {
+		negative = true
+		value = value[1 : len(value)-1]
+	}
+	value = strings.TrimFunc(value, isCurrencySymbol)
+	if strings.HasPrefix(value, "-") {
+		negative = !negative
+		value = strings.TrimFunc(value[1:], isCurrencySymbol)
+	}
+
+	if numberFormat == numberFormatStrict {
+		numberFormat = numberFormatCommaPeriod
+	}
+	if normalized, ok := normalizeNumber(value, numberFormat); ok {
+		value = normalized
+	}
+	if negative {
+		value = "-" + value
+	}
+
+	if !isPlainNumber(value) {
+		return strColValue
+	}
+	return value
+}
+
+// isCurrencySymbol returns whether a rune is a currency symbol or a space around one
+func isCurrencySymbol(r rune) bool {
+	return unicode.Is(unicode.Sc, r) || unicode.IsSpace(r)
+}
+
+// dedupColumns handles the columns of a result set that share their name (e.g. the id of each table of a join)
+// according to the DuplicateColumnPolicy: 'suffix' renames the second one to <name>_2, the third one to <name>_3,
+// etc., 'error' fails the query and 'overwrite' keeps the names, the last one of the columns is sent
+func (bt *Sqlbeat) dedupColumns(index int, columns []string) ([]string, error) {
+	duplicates := duplicateColumns(columns)
+	if len(duplicates) == 0 {
+		return columns, nil
+	}
+
+	switch bt.duplicateColumnPolicy {
+	case duplicateColumnPolicyError:
+		return nil, fmt.Errorf("Query %v returns duplicate columns %v", bt.queryName(index), duplicates)
+	case duplicateColumnPolicyOverwrite:
+		logp.Warn("Query %v returns duplicate columns %v, only the last one of each is sent", bt.queryName(index), duplicates)
+		return columns, nil
+	}
+
+	// The suffixed name must not be the name of another column either
+	seen := make(map[string]bool, len(columns))
+	for _, strColName := range columns {
+		seen[strColName] = true
+	}
+	deduped := make([]string, len(columns))
+	counts := make(map[string]int, len(columns))
+	for i, strColName := range columns {
+		counts[strColName]++
+		name := strColName
+		for count := counts[strColName]; count > 1 && seen[name]; count++ {
+			name = fmt.Sprintf("%v_%d", strColName, count)
+			counts[strColName] = count
+		}
+		seen[name] = true
+		deduped[i] = name
+	}
+	logp.Warn("Query %v returns duplicate columns %v, renamed them with a suffix, the columns are %v", bt.queryName(index), duplicates, deduped)
+	return deduped, nil
+}
+
+// duplicateColumns returns the column names that appear more than once, once for each repeated occurrence
+func duplicateColumns(columns []string) []string {
+	seen := make(map[string]bool, len(columns))
+	var duplicates []string
+	for _, strColName := range columns {
+		if seen[strColName] {
+			duplicates = append(duplicates, strColName)
+		}
+		seen[strColName] = true
+	}
+	return duplicates
+}
@@ -0,0 +1,101 @@
+package beater
+
+import (
+	"sync"
+	"time"
+)
+
+// DeltaStateKey returns the key the old value of a delta column is stored under.
+// The key is composed of the target (`dbtype://hostname:port/database`) and the column name,
+// so targets that return the same delta column name never share its old value.
+func DeltaStateKey(target string, column string) string {
+	return target + "|" + column
+}
+
+// deltaState holds the previous value and age of the delta columns, it's safe for concurrent use
+type deltaState struct {
+	mutex    sync.RWMutex
+	values   map[string]interface{}
+	ages     map[string]time.Time
+	smoothed map[string]float64
+}
+
+// newDeltaState creates an empty deltaState
+func newDeltaState() *deltaState {
+	return &deltaState{
+		values:   make(map[string]interface{}),
+		ages:     make(map[string]time.Time),
+		smoothed: make(map[string]float64),
+	}
+}
+
+// get returns the previous value and age of a delta column, exists is false when there is none
+func (ds *deltaState) get(key string) (value interface{}, age time.Time, exists bool) {
+	ds.mutex.RLock()
+	defer ds.mutex.RUnlock()
+
+	value, exists = ds.values[key]
+	age = ds.ages[key]
+	return value, age, exists
+}
+
+// set saves the current value and age of a delta column
+func (ds *deltaState) set(key string, value interface{}, age time.Time) {
+	ds.mutex.Lock()
+	defer ds.mutex.Unlock()
+
+	ds.values[key] = value
+	ds.ages[key] = age
+}
+
+// smooth adds a delta to the exponentially weighted moving average of a delta column and returns the average,
+// the first delta of a column starts the average
+func (ds *deltaState) smooth(key string, delta float64, alpha float64) float64 {
+	ds.mutex.Lock()
+	defer ds.mutex.Unlock()
+
+	average, exists := ds.smoothed[key]
+	if exists {
+		average = alpha*delta + (1-alpha)*average
+	} else {
+		average = delta
+	}
+
+	ds.smoothed[key] = average
+	return average
+}
+
+// delete removes a delta column from the state
+func (ds *deltaState) delete(key string) {
+	ds.mutex.Lock()
+	defer ds.mutex.Unlock()
+
+	delete(ds.values, key)
+	delete(ds.ages, key)
+	delete(ds.smoothed, key)
+}
+
+// prune removes the delta columns last set before the given time and returns how many were removed
+func (ds *deltaState) prune(before time.Time) int {
+	ds.mutex.Lock()
+	defer ds.mutex.Unlock()
+
+	pruned := 0
+	for key, age := range ds.ages {
+		if age.Before(before) {
+			delete(ds.values, key)
+			delete(ds.ages, key)
+			delete(ds.smoothed, key)
+			pruned++
+		}
+	}
+	return pruned
+}
+
+// len returns the number of delta columns in the state
+func (ds *deltaState) len() int {
+	ds.mutex.RLock()
+	defer ds.mutex.RUnlock()
+
+	return len(ds.values)
+}
@@ -0,0 +1,462 @@
+package beater
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/elastic/beats/libbeat/common"
+)
+
+func newDeltaTestBeat() *Sqlbeat {
+	return &Sqlbeat{
+		deltaWildcard:   defaultDeltaWildcard,
+		deltaOutputMode: deltaOutputModeRate,
+		floatPrecision:  defaultFloatPrecision,
+		deltaState:      newDeltaState(),
+		metrics:         &beatMetrics{},
+	}
+}
+
+func TestDeltaStateGetSet(t *testing.T) {
+	ds := newDeltaState()
+	now := time.Now()
+
+	if _, _, exists := ds.get("col__DELTA"); exists {
+		t.Fatal("expected no value in an empty state")
+	}
+
+	ds.set("col__DELTA", int64(10), now)
+
+	value, age, exists := ds.get("col__DELTA")
+	if !exists || value != int64(10) || !age.Equal(now) {
+		t.Fatalf("unexpected state: value=%v age=%v exists=%v", value, age, exists)
+	}
+}
+
+// Run with -race, the delta path is hammered from multiple goroutines
+func TestSetColumnValueConcurrentDelta(t *testing.T) {
+	bt := newDeltaTestBeat()
+	start := time.Now()
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 8)
+
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+
+			colName := fmt.Sprintf("col%d%v", g, defaultDeltaWildcard)
+			for i := 0; i < 500; i++ {
+				event := common.MapStr{}
+				rowAge := start.Add(time.Duration(i) * time.Second)
+
+				// A column shared by all goroutines and one owned by this goroutine
+				bt.setColumnValue(event, "shared"+defaultDeltaWildcard, strconv.Itoa(i), true, rowAge)
+				bt.setColumnValue(event, colName, strconv.Itoa(i*10), true, rowAge)
+
+				if i > 0 && event[colName] != int64(10) {
+					errs <- fmt.Errorf("%v: expected a rate of 10, got %v", colName, event[colName])
+					return
+				}
+			}
+		}(g)
+	}
+
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		t.Error(err)
+	}
+
+	if n := bt.deltaState.len(); n != 9 {
+		t.Errorf("expected 9 delta columns in the state, got %d", n)
+	}
+}
+
+func TestDeltaStateIsolatedPerTarget(t *testing.T) {
+	ds := newDeltaState()
+	start := time.Now()
+
+	// Two targets sharing the same delta state and delta column name
+	primary := newDeltaTestBeat()
+	primary.target = "mysql://primary:3306/app"
+	primary.deltaState = ds
+
+	replica := newDeltaTestBeat()
+	replica.target = "mysql://replica:3306/app"
+	replica.deltaState = ds
+
+	colName := "queries" + defaultDeltaWildcard
+	for i := 0; i < 3; i++ {
+		rowAge := start.Add(time.Duration(i) * 10 * time.Second)
+		primaryEvent := common.MapStr{}
+		replicaEvent := common.MapStr{}
+
+		// The primary grows by 100/s and the replica by 10/s
+		primary.setColumnValue(primaryEvent, colName, strconv.Itoa(1000000+i*1000), true, rowAge)
+		replica.setColumnValue(replicaEvent, colName, strconv.Itoa(i*100), true, rowAge)
+
+		if i == 0 {
+			continue
+		}
+		if primaryEvent[colName] != int64(100) {
+			t.Errorf("primary: expected a rate of 100, got %v", primaryEvent[colName])
+		}
+		if replicaEvent[colName] != int64(10) {
+			t.Errorf("replica: expected a rate of 10, got %v", replicaEvent[colName])
+		}
+	}
+
+	if n := ds.len(); n != 2 {
+		t.Errorf("expected 2 delta columns in the state, got %d", n)
+	}
+}
+
+func TestSetColumnValueSameTimeSamples(t *testing.T) {
+	bt := newDeltaTestBeat()
+	colName := "queries" + defaultDeltaWildcard
+	start := time.Now()
+
+	bt.setColumnValue(common.MapStr{}, colName, "100", true, start)
+
+	// A second sample within the same millisecond would divide by zero
+	event := common.MapStr{}
+	bt.setColumnValue(event, colName, "200", true, start.Add(time.Microsecond/2))
+	if value, ok := event[colName]; ok {
+		t.Fatalf("expected no delta for samples within the same millisecond, got %v", value)
+	}
+
+	event = common.MapStr{}
+	bt.setColumnValue(event, colName, "200", true, start)
+	if value, ok := event[colName]; ok {
+		t.Fatalf("expected no delta for samples at the same time, got %v", value)
+	}
+
+	// The next delta is calculated from the first sample
+	event = common.MapStr{}
+	bt.setColumnValue(event, colName, "300", true, start.Add(2*time.Second))
+	if event[colName] != int64(100) {
+		t.Errorf("expected a rate of 100, got %v", event[colName])
+	}
+}
+
+func TestSetColumnValueDeltaMinInterval(t *testing.T) {
+	bt := newDeltaTestBeat()
+	bt.deltaMinInterval = time.Second
+	colName := "queries" + defaultDeltaWildcard
+	start := time.Now()
+
+	bt.setColumnValue(common.MapStr{}, colName, "100", true, start)
+
+	event := common.MapStr{}
+	bt.setColumnValue(event, colName, "110", true, start.Add(time.Millisecond))
+	if value, ok := event[colName]; ok {
+		t.Fatalf("expected no delta for an interval shorter than DeltaMinInterval, got %v", value)
+	}
+
+	event = common.MapStr{}
+	bt.setColumnValue(event, colName, "150", true, start.Add(time.Second))
+	if event[colName] != int64(50) {
+		t.Errorf("expected a rate of 50, got %v", event[colName])
+	}
+}
+
+func TestDeltaStatePrune(t *testing.T) {
+	ds := newDeltaState()
+	now := time.Now()
+
+	ds.set("stale", int64(1), now.Add(-2*time.Hour))
+	ds.set("fresh", int64(1), now)
+	ds.smooth("stale", 1, 0.5)
+
+	if pruned := ds.prune(now.Add(-time.Hour)); pruned != 1 {
+		t.Errorf("expected 1 pruned delta column, got %d", pruned)
+	}
+	if _, _, exists := ds.get("stale"); exists {
+		t.Error("expected the stale delta column to be dropped")
+	}
+	if _, exists := ds.smoothed["stale"]; exists {
+		t.Error("expected the smoothed delta of the stale column to be dropped")
+	}
+	if _, _, exists := ds.get("fresh"); !exists {
+		t.Error("expected the fresh delta column to be kept")
+	}
+}
+
+func TestSetColumnValueDeltaMaxAge(t *testing.T) {
+	bt := newDeltaTestBeat()
+	bt.deltaMaxAge = time.Minute
+	colName := "queries" + defaultDeltaWildcard
+	start := time.Now()
+
+	bt.setColumnValue(common.MapStr{}, colName, "100", true, start)
+
+	// The old value expired, the sample is a new baseline
+	event := common.MapStr{}
+	bt.setColumnValue(event, colName, "1000", true, start.Add(time.Hour))
+	if value, ok := event[colName]; ok {
+		t.Fatalf("expected no delta over an expired old value, got %v", value)
+	}
+
+	event = common.MapStr{}
+	bt.setColumnValue(event, colName, "1100", true, start.Add(time.Hour+10*time.Second))
+	if event[colName] != int64(10) {
+		t.Errorf("expected a rate of 10, got %v", event[colName])
+	}
+}
+
+func TestSetColumnValueDeltaNegative(t *testing.T) {
+	bt := newDeltaTestBeat()
+	colName := "free_memory" + defaultDeltaWildcard
+	start := time.Now()
+
+	// Counters are clamped to 0 when the value goes down
+	bt.setColumnValue(common.MapStr{}, colName, "1000", true, start)
+	event := common.MapStr{}
+	bt.setColumnValue(event, colName, "800", true, start.Add(10*time.Second))
+	if event[colName] != int64(0) {
+		t.Errorf("expected a counter delta of 0, got %v", event[colName])
+	}
+
+	// Gauges report the signed rate of change, both falling and rising
+	bt.deltaNegativeColumns = map[string]bool{colName: true}
+	event = common.MapStr{}
+	bt.setColumnValue(event, colName, "575", true, start.Add(20*time.Second))
+	if event[colName] != int64(-23) {
+		t.Errorf("expected a rate of -23, got %v", event[colName])
+	}
+	event = common.MapStr{}
+	bt.setColumnValue(event, colName, "775", true, start.Add(30*time.Second))
+	if event[colName] != int64(20) {
+		t.Errorf("expected a rate of 20, got %v", event[colName])
+	}
+
+	// Floats and uints crossing below their old value
+	bt.deltaAllowNegative = true
+	bt.deltaNegativeColumns = nil
+	bt.setColumnValue(common.MapStr{}, "load"+defaultDeltaWildcard, "2.5", true, start)
+	event = common.MapStr{}
+	bt.setColumnValue(event, "load"+defaultDeltaWildcard, "1.5", true, start.Add(10*time.Second))
+	if event["load"+defaultDeltaWildcard] != -0.1 {
+		t.Errorf("expected a rate of -0.1, got %v", event["load"+defaultDeltaWildcard])
+	}
+
+	bt.deltaOutputMode = deltaOutputModeIncrement
+	bt.setColumnValue(common.MapStr{}, "bytes"+defaultDeltaWildcard, "18446744073709551615", true, start)
+	event = common.MapStr{}
+	bt.setColumnValue(event, "bytes"+defaultDeltaWildcard, "18446744073709551515", true, start.Add(10*time.Second))
+	if event["bytes"+defaultDeltaWildcard] != int64(-100) {
+		t.Errorf("expected an increment of -100, got %v (%T)", event["bytes"+defaultDeltaWildcard], event["bytes"+defaultDeltaWildcard])
+	}
+}
+
+func TestSetColumnValueDeltaWarmup(t *testing.T) {
+	bt := newDeltaTestBeat()
+	bt.deltaWarmupCycles = 2
+	start := time.Now()
+
+	for cycle := 1; cycle <= 3; cycle++ {
+		bt.tickCount = cycle
+		event := common.MapStr{}
+		bt.setColumnValue(event, "queries__DELTA", strconv.Itoa(cycle*10), true, start.Add(time.Duration(cycle)*time.Second))
+		_, sent := event["queries__DELTA"]
+		if sent != (cycle == 3) {
+			t.Errorf("cycle %d: expected the delta to be sent only after the warm-up, got %v", cycle, event)
+		}
+	}
+}
+
+func TestIsDeltaColumn(t *testing.T) {
+	bt := newDeltaTestBeat()
+	bt.queryDeltaWildcards = []string{"", deltaWildcardDisabled, "_rate"}
+
+	if !bt.isDeltaColumn(0, "queries__DELTA") {
+		t.Errorf("expected the DeltaWildcard to apply to query 0")
+	}
+	if bt.isDeltaColumn(1, "queries__DELTA") {
+		t.Errorf("expected the delta columns of query 1 to be disabled")
+	}
+	if bt.isDeltaColumn(2, "queries__DELTA") || !bt.isDeltaColumn(2, "queries_rate") {
+		t.Errorf("expected query 2 to use its own delta wildcard")
+	}
+	if !bt.isDeltaColumn(3, "queries__DELTA") {
+		t.Errorf("expected the DeltaWildcard to apply to a query without an entry")
+	}
+}
+
+func TestSetRawDeltaValue(t *testing.T) {
+	bt := newDeltaTestBeat()
+	bt.emitRawWithDelta = true
+	bt.queryDeltaWildcards = []string{"_rate"}
+	start := time.Now()
+
+	for i, value := range []string{"100", "150"} {
+		event := common.MapStr{}
+		rowAge := start.Add(time.Duration(i*10) * time.Second)
+		bt.setColumnValue(event, "queries_rate", value, true, rowAge)
+		bt.setRawDeltaValue(event, 0, "queries_rate", value, columnTypeAuto, rowAge)
+
+		if raw, _ := event["queries"].(int64); fmt.Sprint(raw) != value {
+			t.Errorf("expected the raw value %v, got %v", value, event)
+		}
+		if i == 1 && event["queries_rate"] != int64(5) {
+			t.Errorf("expected a rate of 5, got %v", event["queries_rate"])
+		}
+	}
+}
+
+func TestLabelDeltaValue(t *testing.T) {
+	bt := newDeltaTestBeat()
+	bt.deltaFieldSuffix = "_per_sec"
+	bt.includeDeltaUnit = true
+	bt.includeDeltaInterval = true
+	start := time.Now()
+
+	var event common.MapStr
+	for i, value := range []string{"100", "150"} {
+		event = common.MapStr{}
+		bt.setColumnValue(event, "Com_select__DELTA", value, true, start.Add(time.Duration(i*10)*time.Second))
+		bt.labelDeltaValue(event, 0, "Com_select__DELTA")
+	}
+
+	expected := common.MapStr{
+		"Com_select_per_sec":                  int64(5),
+		"Com_select_per_sec_interval_seconds": float64(10),
+		"Com_select_per_sec_unit":             deltaUnitPerSecond,
+	}
+	if !reflect.DeepEqual(event, expected) {
+		t.Errorf("expected %v, got %v", expected, event)
+	}
+}
+
+func TestDeltaTimestamps(t *testing.T) {
+	bt := newDeltaTestBeat()
+	bt.deltaFieldSuffix = "_per_sec"
+	bt.includeDeltaTimestamps = true
+	start := time.Now()
+
+	// Com_insert only has a previous sample from the second cycle, so its window starts later
+	bt.setColumnValue(common.MapStr{}, "Com_select__DELTA", "100", true, start)
+	bt.setColumnValue(common.MapStr{}, "Com_insert__DELTA", "10", true, start.Add(10*time.Second))
+
+	event := common.MapStr{}
+	end := start.Add(20 * time.Second)
+	bt.setColumnValue(event, "Com_select__DELTA", "300", true, end)
+	bt.labelDeltaValue(event, 0, "Com_select__DELTA")
+	bt.setColumnValue(event, "Com_insert__DELTA", "60", true, end)
+	bt.labelDeltaValue(event, 0, "Com_insert__DELTA")
+
+	expected := common.MapStr{
+		"Com_select_per_sec":             int64(10),
+		"Com_select_per_sec_delta_start": common.Time(start),
+		"Com_select_per_sec_delta_end":   common.Time(end),
+		"Com_insert_per_sec":             int64(5),
+		"Com_insert_per_sec_delta_start": common.Time(start.Add(10 * time.Second)),
+		"Com_insert_per_sec_delta_end":   common.Time(end),
+	}
+	if !reflect.DeepEqual(event, expected) {
+		t.Errorf("expected %#v, got %#v", expected, event)
+	}
+}
+
+func TestDeltaOutputModes(t *testing.T) {
+	tests := []struct {
+		mode     string
+		oldValue string
+		newValue string
+		expected interface{}
+	}{
+		{deltaOutputModeRate, "100", "150", int64(5)},
+		{deltaOutputModeIncrement, "100", "150", int64(50)},
+		{deltaOutputModeCumulative, "100", "150", int64(150)},
+		{deltaOutputModeRate, "18446744073709551515", "18446744073709551615", uint64(10)},
+		{deltaOutputModeIncrement, "18446744073709551515", "18446744073709551615", uint64(100)},
+		{deltaOutputModeCumulative, "18446744073709551515", "18446744073709551615", uint64(18446744073709551615)},
+		{deltaOutputModeRate, "1.5", "4.5", 0.3},
+		{deltaOutputModeIncrement, "1.5", "4.5", 3.0},
+		{deltaOutputModeCumulative, "1.5", "4.5", 4.5},
+
+		// A counter reset (a lower value) is a delta of 0, cumulative reports the new raw counter
+		{deltaOutputModeRate, "150", "20", int64(0)},
+		{deltaOutputModeIncrement, "150", "20", int64(0)},
+		{deltaOutputModeCumulative, "150", "20", int64(20)},
+		{deltaOutputModeRate, "18446744073709551615", "20", int64(0)},
+		{deltaOutputModeIncrement, "18446744073709551615", "20", int64(0)},
+		{deltaOutputModeRate, "4.5", "1.5", 0.0},
+		{deltaOutputModeIncrement, "4.5", "1.5", 0.0},
+	}
+
+	colName := "queries" + defaultDeltaWildcard
+	for _, test := range tests {
+		bt := newDeltaTestBeat()
+		bt.deltaOutputMode = test.mode
+		start := time.Now()
+
+		bt.setColumnValue(common.MapStr{}, colName, test.oldValue, true, start)
+		event := common.MapStr{}
+		bt.setColumnValue(event, colName, test.newValue, true, start.Add(10*time.Second))
+
+		if event[colName] != test.expected {
+			t.Errorf("%v from %v to %v: expected %v (%T), got %v (%T)", test.mode, test.oldValue, test.newValue,
+				test.expected, test.expected, event[colName], event[colName])
+		}
+	}
+}
+
+func TestRoundFloat(t *testing.T) {
+	tests := []struct {
+		precision int
+		value     float64
+		expected  float64
+	}{
+		{-1, 1.23456, 1.23456},
+		{0, 2.5, 3},
+		{0, -2.5, -3},
+		{0, -0.4, 0},
+		{2, 1.23456, 1.23},
+		{2, -1.23556, -1.24},
+		{3, 0.0005, 0.001},
+	}
+
+	for _, test := range tests {
+		bt := newDeltaTestBeat()
+		bt.floatPrecision = test.precision
+		if rounded := bt.roundFloat(test.value); rounded != test.expected {
+			t.Errorf("expected %v rounded to %d decimals to be %v, got %v", test.value, test.precision, test.expected, rounded)
+		}
+	}
+}
+
+func TestSetColumnValueFloatPrecision(t *testing.T) {
+	bt := newDeltaTestBeat()
+	bt.floatPrecision = 2
+	colName := "load" + defaultDeltaWildcard
+	start := time.Now()
+
+	// The raw floats and the deltas are both rounded
+	event := common.MapStr{}
+	bt.setColumnValue(event, "uptime_ratio", "0.98765", false, start)
+	if event["uptime_ratio"] != 0.99 {
+		t.Errorf("expected a value of 0.99, got %v", event["uptime_ratio"])
+	}
+
+	bt.setColumnValue(common.MapStr{}, colName, "1.5", true, start)
+	event = common.MapStr{}
+	bt.setColumnValue(event, colName, "2.5", true, start.Add(3*time.Second))
+	if event[colName] != 0.33 {
+		t.Errorf("expected a rate of 0.33, got %v", event[colName])
+	}
+
+	bt.deltaAllowNegative = true
+	event = common.MapStr{}
+	bt.setColumnValue(event, colName, "0.5", true, start.Add(6*time.Second))
+	if event[colName] != -0.67 {
+		t.Errorf("expected a rate of -0.67, got %v", event[colName])
+	}
+}
@@ -0,0 +1,49 @@
+package beater
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/elastic/beats/libbeat/common"
+	"github.com/elastic/beats/libbeat/logp"
+)
+
+// queryer runs queries on the pool (sql.DB) or on a single connection (sql.Conn)
+type queryer interface {
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+	PingContext(ctx context.Context) error
+}
+
+// queryConn returns what a query runs on. MySQL reports the warnings of a query to the connection that ran it,
+// so with IncludeQueryDiagnostics MySQL queries run on a connection of their own, which is closed by done
+func (bt *Sqlbeat) queryConn(ctx context.Context, db *sql.DB) (q queryer, conn *sql.Conn, err error) {
+	if !bt.includeQueryDiagnostics || bt.dbType != dbtMySQL {
+		return db, nil, nil
+	}
+	conn, err = db.Conn(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	return conn, conn, nil
+}
+
+// queryDiagnostics returns the sqlbeat fields describing how a query went: the error that ended reading its rows,
+// if any, and the number of warnings of the query (MySQL only, read on the connection that ran the query)
+func (bt *Sqlbeat) queryDiagnostics(ctx context.Context, conn *sql.Conn, index int, queryErr error) common.MapStr {
+	diagnostics := common.MapStr{}
+	if queryErr != nil {
+		diagnostics["query_error"] = queryErr.Error()
+	}
+
+	if conn != nil {
+		var warnings int
+		err := conn.QueryRowContext(ctx, "SHOW COUNT(*) WARNINGS").Scan(&warnings)
+		if err != nil {
+			logp.Warn("Query %v error getting its warnings count: %v %s", bt.queryName(index), err, bt.queryLogFields(index, err))
+		} else {
+			diagnostics["warnings"] = warnings
+		}
+	}
+
+	return diagnostics
+}
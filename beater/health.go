@@ -0,0 +1,57 @@
+package beater
+
+import (
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// beatHealth tracks the last cycle that completed with the DB reachable for the health check, it's safe for
+// concurrent use
+type beatHealth struct {
+	mutex       sync.Mutex
+	lastSuccess time.Time
+	staleAfter  time.Duration
+}
+
+// setStaleAfter sets how long after the last successful cycle the beat is unhealthy, it's set again on reload
+func (bh *beatHealth) setStaleAfter(staleAfter time.Duration) {
+	bh.mutex.Lock()
+	defer bh.mutex.Unlock()
+
+	bh.staleAfter = staleAfter
+}
+
+// addSuccess records a cycle that completed with the DB reachable, failed queries don't fail the health check
+func (bh *beatHealth) addSuccess(now time.Time) {
+	bh.mutex.Lock()
+	defer bh.mutex.Unlock()
+
+	bh.lastSuccess = now
+}
+
+// check returns an error when no cycle completed with the DB reachable within staleAfter
+func (bh *beatHealth) check(now time.Time) error {
+	bh.mutex.Lock()
+	defer bh.mutex.Unlock()
+
+	if bh.lastSuccess.IsZero() {
+		return fmt.Errorf("no cycle completed successfully yet")
+	}
+	if age := now.Sub(bh.lastSuccess); age > bh.staleAfter {
+		return fmt.Errorf("the last successful cycle was %v ago (stale after %v)", age.Round(time.Second), bh.staleAfter)
+	}
+	return nil
+}
+
+// ServeHTTP answers 200 while the beat is healthy and 503 otherwise, for liveness and readiness probes
+func (bh *beatHealth) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain")
+	if err := bh.check(time.Now()); err != nil {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		fmt.Fprintf(w, "unhealthy: %v\n", err)
+		return
+	}
+	fmt.Fprintln(w, "ok")
+}
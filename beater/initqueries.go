@@ -0,0 +1,96 @@
+package beater
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+)
+
+// initConnector runs the InitQueries on every new connection of a pool before the pool hands it out, so the
+// session state they prepare is there for every query, including on the connections opened after a reconnect
+type initConnector struct {
+	driver.Connector
+	queries []string
+}
+
+// Connect opens a connection and runs the init queries on it, the connection is discarded when one fails
+func (ic *initConnector) Connect(ctx context.Context) (driver.Conn, error) {
+	conn, err := ic.Connector.Connect(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	for index, query := range ic.queries {
+		err = execConn(ctx, conn, query)
+		if err != nil {
+			conn.Close()
+			return nil, fmt.Errorf("Error running init query #%d: %v", index, err)
+		}
+	}
+	return conn, nil
+}
+
+// execConn runs a statement without arguments on a driver connection
+func execConn(ctx context.Context, conn driver.Conn, query string) error {
+	if execer, ok := conn.(driver.ExecerContext); ok {
+		_, err := execer.ExecContext(ctx, query, nil)
+		if err != driver.ErrSkip {
+			return err
+		}
+	}
+
+	// The driver has no direct exec, run the statement through a prepared statement
+	stmt, err := conn.Prepare(query)
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+	_, err = stmt.Exec(nil)
+	return err
+}
+
+// dsnConnector is the connector of the drivers that don't provide one, it opens connections with the DSN
+type dsnConnector struct {
+	driver driver.Driver
+	dsn    string
+}
+
+// Connect opens a connection with the DSN
+func (dc *dsnConnector) Connect(context.Context) (driver.Conn, error) {
+	return dc.driver.Open(dc.dsn)
+}
+
+// Driver returns the driver of the connector
+func (dc *dsnConnector) Driver() driver.Driver {
+	return dc.driver
+}
+
+// openSQL opens a connection pool, like sql.Open, whose connections run the InitQueries when they are opened.
+// With AuthMode awsiam the connections are opened with the current RDS auth token
+func (bt *Sqlbeat) openSQL(connString string) (*sql.DB, error) {
+	db, err := sql.Open(bt.driverName(), connString)
+	if err != nil || (len(bt.initQueries) == 0 && bt.authMode != authModeAWSIAM) {
+		return db, err
+	}
+
+	// sql.Open doesn't connect, the pool is only opened to get the registered driver
+	sqlDriver := db.Driver()
+	db.Close()
+
+	var connector driver.Connector
+	if bt.authMode == authModeAWSIAM {
+		connector = &authTokenConnector{driver: sqlDriver, dbType: bt.dbType, connString: connString, token: bt.authToken}
+	} else if driverContext, ok := sqlDriver.(driver.DriverContext); ok {
+		connector, err = driverContext.OpenConnector(connString)
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		connector = &dsnConnector{driver: sqlDriver, dsn: connString}
+	}
+	if len(bt.initQueries) == 0 {
+		return sql.OpenDB(connector), nil
+	}
+	return sql.OpenDB(&initConnector{Connector: connector, queries: bt.initQueries}), nil
+}
@@ -0,0 +1,41 @@
+package beater
+
+import (
+	"bytes"
+	"fmt"
+)
+
+// logFields formats key/value pairs as `[key=value ...]` in the given order, appended to the human-readable
+// log messages so log pipelines can parse the errors by stable keys. String and error values are quoted
+func logFields(keysAndValues ...interface{}) string {
+	var buf bytes.Buffer
+	buf.WriteString("[")
+
+	for i := 0; i+1 < len(keysAndValues); i += 2 {
+		if i > 0 {
+			buf.WriteString(" ")
+		}
+
+		switch value := keysAndValues[i+1].(type) {
+		case string:
+			fmt.Fprintf(&buf, "%v=%q", keysAndValues[i], value)
+		case error:
+			fmt.Fprintf(&buf, "%v=%q", keysAndValues[i], value.Error())
+		default:
+			fmt.Fprintf(&buf, "%v=%v", keysAndValues[i], value)
+		}
+	}
+
+	buf.WriteString("]")
+	return buf.String()
+}
+
+// queryLogFields returns the log fields of a query error
+func (bt *Sqlbeat) queryLogFields(index int, err error) string {
+	return logFields("query_index", index, "query_name", bt.queryName(index), "db_type", bt.dbType, "error", err)
+}
+
+// connectionLogFields returns the log fields of a connection error
+func (bt *Sqlbeat) connectionLogFields(err error) string {
+	return logFields("db_type", bt.dbType, "endpoint", bt.endpoint(), "error", err)
+}
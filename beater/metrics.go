@@ -0,0 +1,113 @@
+package beater
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/elastic/beats/libbeat/logp"
+)
+
+// beatMetrics holds the totals of the beat's own stats since it started, it's safe for concurrent use
+type beatMetrics struct {
+	mutex            sync.Mutex
+	ticks            int
+	queries          int
+	events           int
+	errors           int
+	queryErrors      map[string]int
+	lastTickDuration time.Duration
+}
+
+// addTick adds the counters of a completed tick to the totals
+func (bm *beatMetrics) addTick(ts *tickStats) {
+	queries, events, errs := ts.counts()
+	duration := time.Since(ts.start)
+
+	bm.mutex.Lock()
+	defer bm.mutex.Unlock()
+
+	bm.ticks++
+	bm.queries += queries
+	bm.events += events
+	bm.errors += errs
+	bm.lastTickDuration = duration
+}
+
+// addError counts an error that occurred outside of a tick
+func (bm *beatMetrics) addError() {
+	bm.mutex.Lock()
+	defer bm.mutex.Unlock()
+
+	bm.errors++
+}
+
+// addQueryError counts an error of a query by its name, the error is also counted in the errors of its tick
+func (bm *beatMetrics) addQueryError(query string) {
+	bm.mutex.Lock()
+	defer bm.mutex.Unlock()
+
+	if bm.queryErrors == nil {
+		bm.queryErrors = make(map[string]int)
+	}
+	bm.queryErrors[query]++
+}
+
+// ServeHTTP writes the metrics in the Prometheus text format
+func (bm *beatMetrics) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	bm.mutex.Lock()
+	defer bm.mutex.Unlock()
+
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	writeMetric(w, "sqlbeat_ticks_total", "counter", "Number of periods the queries ran in.", bm.ticks)
+	writeMetric(w, "sqlbeat_queries_total", "counter", "Number of queries that ran.", bm.queries)
+	writeMetric(w, "sqlbeat_events_published_total", "counter", "Number of query events published.", bm.events)
+	writeMetric(w, "sqlbeat_errors_total", "counter", "Number of errors.", bm.errors)
+	writeMetric(w, "sqlbeat_tick_duration_seconds", "gauge", "Duration of the last period.", bm.lastTickDuration.Seconds())
+
+	// The errors of each query, sorted by query so the output is stable
+	queries := make([]string, 0, len(bm.queryErrors))
+	for query := range bm.queryErrors {
+		queries = append(queries, query)
+	}
+	sort.Strings(queries)
+	fmt.Fprintf(w, "# HELP sqlbeat_query_errors_total Number of errors of each query.\n# TYPE sqlbeat_query_errors_total counter\n")
+	for _, query := range queries {
+		fmt.Fprintf(w, "sqlbeat_query_errors_total{query=%s} %d\n", strconv.Quote(query), bm.queryErrors[query])
+	}
+}
+
+// writeMetric writes a single metric with its help and type
+func writeMetric(w http.ResponseWriter, name string, metricType string, help string, value interface{}) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n%s %v\n", name, help, name, metricType, name, value)
+}
+
+// startHTTPServer serves the handlers on their paths of the given address (the metrics on /metrics, the health
+// check on /healthz), the server runs until it's closed
+func startHTTPServer(addr string, handlers map[string]http.Handler) (*http.Server, error) {
+	// Listen first so a bad address fails the beat instead of only being logged
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("Error listening for HTTP on %v: %v", addr, err)
+	}
+
+	mux := http.NewServeMux()
+	for path, handler := range handlers {
+		mux.Handle(path, handler)
+		logp.Info("Serving http://%v%v", listener.Addr(), path)
+	}
+	server := &http.Server{Handler: mux}
+
+	go func() {
+		err := server.Serve(listener)
+		if err != nil && err != http.ErrServerClosed {
+			logp.Err("HTTP server error on %v: %v", addr, err)
+		}
+	}()
+
+	return server, nil
+}
@@ -0,0 +1,56 @@
+package beater
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"sync"
+
+	"github.com/elastic/beats/libbeat/common"
+)
+
+// eventHashState holds the hash of the events of the previous cycle of the publish-on-change queries, it's safe
+// for concurrent use. Hashes are stored per target and query, like the snapshots
+type eventHashState struct {
+	mutex  sync.Mutex
+	hashes map[string]string
+}
+
+// newEventHashState creates an empty eventHashState
+func newEventHashState() *eventHashState {
+	return &eventHashState{
+		hashes: make(map[string]string),
+	}
+}
+
+// changed stores the hash of the events of the current cycle and reports whether it differs from the hash of the
+// previous cycle, the first cycle is always a change
+func (hs *eventHashState) changed(key string, hash string) bool {
+	hs.mutex.Lock()
+	defer hs.mutex.Unlock()
+
+	previous, exists := hs.hashes[key]
+	hs.hashes[key] = hash
+	return !exists || previous != hash
+}
+
+// hashEvents returns the hash of the events of a cycle without their @timestamp, which changes every cycle
+func hashEvents(events []common.MapStr) (string, error) {
+	fields := make([]common.MapStr, len(events))
+	for index, event := range events {
+		fields[index] = common.MapStr{}
+		for key, value := range event {
+			if key != "@timestamp" {
+				fields[index][key] = value
+			}
+		}
+	}
+
+	// Map keys are marshaled in sorted order, so equal events always have the same hash
+	data, err := json.Marshal(fields)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:]), nil
+}
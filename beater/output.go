@@ -0,0 +1,86 @@
+package beater
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+
+	"github.com/elastic/beats/libbeat/beat"
+	"github.com/elastic/beats/libbeat/common"
+	"github.com/elastic/beats/libbeat/logp"
+)
+
+// debugOutput writes the events as NDJSON (one JSON document per line) to a file for testing configs without
+// an Elasticsearch, it's safe for concurrent use
+type debugOutput struct {
+	mutex   sync.Mutex
+	file    *os.File
+	encoder *json.Encoder
+}
+
+// newDebugOutput opens the debug output file, the events are appended to an existing file
+func newDebugOutput(path string) (*debugOutput, error) {
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0640)
+	if err != nil {
+		return nil, fmt.Errorf("Error opening DebugOutputFile %v: %v", path, err)
+	}
+	return &debugOutput{file: file, encoder: json.NewEncoder(file)}, nil
+}
+
+// write writes the events to the file, an event that can't be written is logged and skipped
+func (do *debugOutput) write(events []common.MapStr) {
+	do.mutex.Lock()
+	defer do.mutex.Unlock()
+
+	for _, event := range events {
+		if err := do.encoder.Encode(event); err != nil {
+			logp.Err("Error writing an event to DebugOutputFile %v: %v", do.file.Name(), err)
+		}
+	}
+}
+
+// close closes the file
+func (do *debugOutput) close() {
+	do.mutex.Lock()
+	defer do.mutex.Unlock()
+
+	do.file.Close()
+}
+
+// publish publishes events to the beat's output and writes them to the DebugOutputFile (if any), with
+// DebugOutputOnly the events are only written to the file
+func (bt *Sqlbeat) publish(b *beat.Beat, events ...common.MapStr) {
+	if bt.debugOutput != nil {
+		bt.debugOutput.write(events)
+		if bt.debugOutputOnly {
+			return
+		}
+	}
+
+	if len(events) == 1 {
+		b.Events.PublishEvent(events[0])
+		return
+	}
+	b.Events.PublishEvents(events)
+}
+
+// setDocID sets the document id (@metadata._id) of a query event to the value of its DocIDColumn. The Elasticsearch
+// output of the libbeat sqlbeat is built with doesn't read it, Logstash does (document_id => "%{[@metadata][_id]}")
+// so the event overwrites the document of the previous cycles. An event without the column or with an id already
+// used by another event of the cycle is published without a document id
+func (bt *Sqlbeat) setDocID(event common.MapStr, index int, docIDs map[string]bool) {
+	value, ok := event[bt.fieldName(bt.docIDColumn)]
+	if !ok || value == nil {
+		logp.Debug("sqlbeat", "Query %v event has no %v value, publishing it without a document id", bt.queryName(index), bt.docIDColumn)
+		return
+	}
+
+	docID := fmt.Sprint(value)
+	if docIDs[docID] {
+		logp.Warn("Query %v has more than one row with %v '%v', publishing the duplicate without a document id", bt.queryName(index), bt.docIDColumn, docID)
+		return
+	}
+	docIDs[docID] = true
+	event["@metadata"] = common.MapStr{"_id": docID}
+}
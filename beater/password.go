@@ -0,0 +1,78 @@
+package beater
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+)
+
+// EncryptPassword encrypts a password with the compiled secret, the result can be used as EncryptedPassword
+// with the same EncryptionMode. The gcm mode prepends a random nonce to the ciphertext
+func EncryptPassword(password string, mode string) (string, error) {
+	aesCipher, err := aes.NewCipher([]byte(secret))
+	if err != nil {
+		return "", err
+	}
+
+	switch mode {
+	case encryptionModeCFB:
+		cfbEncrypter := cipher.NewCFBEncrypter(aesCipher, commonIV)
+		cipherText := make([]byte, len(password))
+		cfbEncrypter.XORKeyStream(cipherText, []byte(password))
+		return hex.EncodeToString(cipherText), nil
+
+	case encryptionModeGCM:
+		gcm, err := cipher.NewGCM(aesCipher)
+		if err != nil {
+			return "", err
+		}
+		nonce := make([]byte, gcm.NonceSize())
+		if _, err := rand.Read(nonce); err != nil {
+			return "", err
+		}
+		return hex.EncodeToString(gcm.Seal(nonce, nonce, []byte(password), nil)), nil
+	}
+
+	return "", fmt.Errorf("Unknown encryption mode, supported encryption modes: `%v`, `%v`", encryptionModeCFB, encryptionModeGCM)
+}
+
+// decryptPassword decrypts an EncryptedPassword with the compiled secret
+func decryptPassword(encryptedPassword string, mode string) (string, error) {
+	aesCipher, err := aes.NewCipher([]byte(secret))
+	if err != nil {
+		return "", err
+	}
+	chiperText, err := hex.DecodeString(encryptedPassword)
+	if err != nil {
+		return "", err
+	}
+
+	switch mode {
+	case encryptionModeCFB:
+		cfbDecrypter := cipher.NewCFBDecrypter(aesCipher, commonIV)
+		plaintextCopy := make([]byte, len(chiperText))
+		cfbDecrypter.XORKeyStream(plaintextCopy, chiperText)
+		return string(plaintextCopy), nil
+
+	case encryptionModeGCM:
+		gcm, err := cipher.NewGCM(aesCipher)
+		if err != nil {
+			return "", err
+		}
+		if len(chiperText) < gcm.NonceSize() {
+			return "", fmt.Errorf("EncryptedPassword is too short to hold the gcm nonce")
+		}
+
+		// The authentication fails when the password was encrypted with a different secret or tampered with
+		nonce, sealed := chiperText[:gcm.NonceSize()], chiperText[gcm.NonceSize():]
+		plaintext, err := gcm.Open(nil, nonce, sealed, nil)
+		if err != nil {
+			return "", fmt.Errorf("Error decrypting EncryptedPassword: %v", err)
+		}
+		return string(plaintext), nil
+	}
+
+	return "", fmt.Errorf("Unknown encryption mode, supported encryption modes: `%v`, `%v`", encryptionModeCFB, encryptionModeGCM)
+}
@@ -0,0 +1,67 @@
+package beater
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+var (
+	// output parameters names are plain identifiers, their types are T-SQL types like int or decimal(18, 2)
+	outputParamNameRegexp = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+	outputParamTypeRegexp = regexp.MustCompile(`^[A-Za-z][A-Za-z0-9_]*( ?\([0-9, ]*(max|MAX)?\))?$`)
+)
+
+// validateOutputParams makes sure the output parameters of a stored-procedure query can be declared safely
+func validateOutputParams(index int, outputParams map[string]string) error {
+	for name, paramType := range outputParams {
+		if !outputParamNameRegexp.MatchString(name) {
+			return fmt.Errorf("Query #%d output parameter '%v' isn't a valid parameter name", index, name)
+		}
+		if !outputParamTypeRegexp.MatchString(paramType) {
+			return fmt.Errorf("Query #%d output parameter '%v' type '%v' isn't a valid T-SQL type", index, name, paramType)
+		}
+	}
+	return nil
+}
+
+// procedureBatch wraps the EXEC statement of a stored-procedure query in a batch that declares its output
+// parameters and selects them after the procedure's own result sets, so the parameters are read as the last
+// result set (a row with a column per parameter)
+func procedureBatch(queryStr string, outputParams map[string]string) string {
+	if len(outputParams) == 0 {
+		return queryStr
+	}
+
+	// Sort the parameters so the batch is the same on every run
+	names := make([]string, 0, len(outputParams))
+	for name := range outputParams {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	declarations := make([]string, len(names))
+	selections := make([]string, len(names))
+	for i, name := range names {
+		declarations[i] = fmt.Sprintf("@%v %v", name, outputParams[name])
+		selections[i] = fmt.Sprintf("@%v AS [%v]", name, name)
+	}
+
+	return fmt.Sprintf("DECLARE %v;\n%v;\nSELECT %v;",
+		strings.Join(declarations, ", "), strings.TrimRight(strings.TrimSpace(queryStr), ";"), strings.Join(selections, ", "))
+}
+
+// queryStatement returns the statement that runs a query, stored-procedure queries are wrapped in a batch
+// that reads their output parameters and pg-replication-lag queries run the standby query
+func (bt *Sqlbeat) queryStatement(index int, queryStr string) string {
+	switch bt.queryTypes[index] {
+	case queryTypeStoredProcedure:
+		if index < len(bt.queryOutputParams) {
+			return procedureBatch(queryStr, bt.queryOutputParams[index])
+		}
+	case queryTypePgReplicationLag:
+		return pgReplicationLagQuery
+	}
+	return queryStr
+}
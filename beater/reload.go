@@ -0,0 +1,94 @@
+package beater
+
+import (
+	"reflect"
+
+	"github.com/adibendahan/sqlbeat/config"
+	"github.com/elastic/beats/libbeat/beat"
+	"github.com/elastic/beats/libbeat/logp"
+)
+
+// reload re-reads and re-validates the config file and swaps it into the running beat, it runs between
+// ticks so a tick never sees a partially applied config. An invalid config leaves the running config as is
+func (bt *Sqlbeat) reload(b *beat.Beat) error {
+	logp.Info("Reloading the config file")
+
+	// Set the new config up on a fresh beat, so errors don't leave the beat half configured. The deferred
+	// cancel is the candidate's own context, the running context is carried over below
+	candidate := New()
+	defer candidate.cancel()
+	err := candidate.Config(b)
+	if err != nil {
+		return err
+	}
+	err = candidate.Setup(b)
+	candidate.closeTunnel()
+	if err != nil {
+		return err
+	}
+
+	bt.swapIn(candidate)
+
+	logp.Info("Config file reloaded")
+	return nil
+}
+
+// swapIn carries the running state of the beat over to a set up candidate and swaps the candidate in
+func (bt *Sqlbeat) swapIn(candidate *Sqlbeat) {
+	// The SSH tunnel was opened with the old SSH settings, the next connection opens a new one
+	if !reflect.DeepEqual(sshSettings(bt.beatConfig), sshSettings(candidate.beatConfig)) {
+		bt.closeTunnel()
+	}
+
+	// The candidate takes over the running state: the context, the connections, the counters and the outputs
+	candidate.done, candidate.ctx, candidate.cancel = bt.done, bt.ctx, bt.cancel
+	candidate.tunnel = bt.tunnel
+	candidate.authToken = bt.authToken
+	candidate.db, candidate.dbConnString = bt.db, bt.dbConnString
+	candidate.pools, candidate.databases = bt.pools, bt.databases
+	candidate.tickCount, candidate.tick = bt.tickCount, bt.tick
+	candidate.metrics, candidate.health = bt.metrics, bt.health
+	candidate.debugOutput = bt.debugOutput
+
+	// The health check keeps its last successful cycle, its stale window follows the new period / HealthStaleAfter
+	if candidate.healthStaleAfter != bt.healthStaleAfter {
+		logp.Info("The health check is now stale after %v (was %v)", candidate.healthStaleAfter, bt.healthStaleAfter)
+	}
+	candidate.health.setStaleAfter(candidate.healthStaleAfter)
+
+	// The delta columns continue from their previous values unless configured otherwise
+	if !candidate.beatConfig.Sqlbeat.ResetDeltaOnReload {
+		candidate.deltaState = bt.deltaState
+		candidate.snapshotState = bt.snapshotState
+		candidate.eventHashes = bt.eventHashes
+	}
+
+	// The pools, databases and init queries are only applied on connect, force a reconnect on the next tick when
+	// they changed
+	if !reflect.DeepEqual(bt.connectionPools, candidate.connectionPools) ||
+		!reflect.DeepEqual(bt.queryDatabases, candidate.queryDatabases) || !reflect.DeepEqual(bt.initQueries, candidate.initQueries) {
+		candidate.dbConnString = ""
+	}
+
+	if candidate.metricsAddr != bt.metricsAddr {
+		logp.Warn("MetricsAddr changes are only applied on restart, still serving metrics on %v", bt.metricsAddr)
+		candidate.metricsAddr = bt.metricsAddr
+	}
+	if candidate.debugOutputFile != bt.debugOutputFile {
+		logp.Warn("DebugOutputFile changes are only applied on restart, still writing the events to %v", bt.debugOutputFile)
+		candidate.debugOutputFile = bt.debugOutputFile
+	}
+	if candidate.healthAddr != bt.healthAddr {
+		logp.Warn("HealthAddr changes are only applied on restart, still serving the health check on %v", bt.healthAddr)
+		candidate.healthAddr = bt.healthAddr
+	}
+
+	// Swap the validated config and state in at once
+	*bt = *candidate
+}
+
+// sshSettings returns the settings the SSH tunnel is opened with
+func sshSettings(beatConfig *config.Config) []string {
+	return []string{beatConfig.Sqlbeat.SSHHost, beatConfig.Sqlbeat.SSHUser, beatConfig.Sqlbeat.SSHKeyFile,
+		beatConfig.Sqlbeat.SSHKnownHostsFile}
+}
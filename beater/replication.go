@@ -0,0 +1,8 @@
+package beater
+
+// pgReplicationLagQuery is the standby query of pg-replication-lag queries (PostgreSQL 10 and later). The lag is
+// the time since the last replayed transaction, it's 0 on a primary and when the standby replayed all it received
+const pgReplicationLagQuery = `SELECT pg_is_in_recovery() AS in_recovery,
+	CASE WHEN NOT pg_is_in_recovery() OR pg_last_wal_receive_lsn() = pg_last_wal_replay_lsn() THEN 0
+		ELSE EXTRACT(EPOCH FROM now() - pg_last_xact_replay_timestamp())
+	END AS replication_lag_seconds`
@@ -0,0 +1,71 @@
+package beater
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/elastic/beats/libbeat/common"
+)
+
+// snapshotState holds the rows of the previous cycle of the snapshot-diff queries by their key, it's safe for
+// concurrent use. Snapshots are stored per target and query, like the delta columns
+type snapshotState struct {
+	mutex     sync.Mutex
+	snapshots map[string]map[string]common.MapStr
+}
+
+// newSnapshotState creates an empty snapshotState
+func newSnapshotState() *snapshotState {
+	return &snapshotState{
+		snapshots: make(map[string]map[string]common.MapStr),
+	}
+}
+
+// swap stores the rows of the current cycle and returns the rows of the previous cycle,
+// exists is false on the first cycle
+func (ss *snapshotState) swap(key string, rows map[string]common.MapStr) (previous map[string]common.MapStr, exists bool) {
+	ss.mutex.Lock()
+	defer ss.mutex.Unlock()
+
+	previous, exists = ss.snapshots[key]
+	ss.snapshots[key] = rows
+	return previous, exists
+}
+
+// diffSnapshots returns the rows of the current snapshot that weren't in the previous snapshot and the rows of
+// the previous snapshot that aren't in the current one
+func diffSnapshots(previous map[string]common.MapStr, current map[string]common.MapStr) (added []common.MapStr, removed []common.MapStr) {
+	for key, row := range current {
+		if _, ok := previous[key]; !ok {
+			added = append(added, row)
+		}
+	}
+	for key, row := range previous {
+		if _, ok := current[key]; !ok {
+			removed = append(removed, row)
+		}
+	}
+	return added, removed
+}
+
+// snapshotRowKey returns the key of a row of a snapshot-diff query, ok is false when the row has no key
+func snapshotRowKey(event common.MapStr, keyField string) (string, bool) {
+	value, ok := event[keyField]
+	if !ok || value == nil {
+		return "", false
+	}
+	return fmt.Sprint(value), true
+}
+
+// copyEvent returns a copy of a snapshot row (and of its nested fields), so the published event doesn't change the
+// stored snapshot
+func copyEvent(event common.MapStr) common.MapStr {
+	copied := make(common.MapStr, len(event))
+	for key, value := range event {
+		if nested, ok := value.(common.MapStr); ok {
+			value = copyEvent(nested)
+		}
+		copied[key] = value
+	}
+	return copied
+}
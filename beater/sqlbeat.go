@@ -1,94 +1,419 @@
 package beater
 
 import (
-	"crypto/aes"
-	"crypto/cipher"
+	"context"
+	"crypto/sha256"
 	"database/sql"
+	"database/sql/driver"
+	"encoding/base64"
 	"encoding/hex"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"math"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"os/signal"
+	"path"
+	"regexp"
+	"sort"
 	"strconv"
 	"strings"
+	"sync"
+	"syscall"
 	"time"
+	"unicode"
 
 	"github.com/adibendahan/sqlbeat/config"
 	"github.com/elastic/beats/libbeat/beat"
 	"github.com/elastic/beats/libbeat/cfgfile"
 	"github.com/elastic/beats/libbeat/common"
 	"github.com/elastic/beats/libbeat/logp"
+	"golang.org/x/crypto/ssh"
 
 	// sql go drivers
+	_ "github.com/ClickHouse/clickhouse-go"
 	_ "github.com/denisenkom/go-mssqldb"
+	"github.com/denisenkom/go-mssqldb/azuread"
 	_ "github.com/go-sql-driver/mysql"
 	_ "github.com/lib/pq"
+	_ "github.com/viant/bigquery"
 )
 
 // Sqlbeat is a struct to hold the beat config & info
 type Sqlbeat struct {
-	beatConfig      *config.Config
-	done            chan struct{}
-	period          time.Duration
-	dbType          string
-	hostname        string
-	port            string
-	username        string
-	password        string
-	passwordAES     string
-	database        string
-	postgresSSLMode string
-	queries         []string
-	queryTypes      []string
-	deltaWildcard   string
-
-	oldValues    common.MapStr
-	oldValuesAge common.MapStr
+	beatConfig              *config.Config
+	done                    chan struct{}
+	ctx                     context.Context
+	cancel                  context.CancelFunc
+	period                  time.Duration
+	dbType                  string
+	connString              string
+	applicationName         string
+	hostname                string
+	hostnames               []string
+	port                    string
+	username                string
+	password                string
+	passwordAES             string
+	authMode                string
+	awsRegion               string
+	authToken               *authToken
+	sshHost                 string
+	sshClientConfig         *ssh.ClientConfig
+	tunnel                  *sshTunnel
+	database                string
+	postgresSSLMode         string
+	postgresSSLCert         string
+	postgresSSLKey          string
+	postgresSSLRootCert     string
+	dsnParams               map[string]string
+	mysqlTimeout            time.Duration
+	mysqlReadTimeout        time.Duration
+	mysqlWriteTimeout       time.Duration
+	mysqlInterpolateParams  bool
+	bigQueryProject         string
+	bigQueryDataset         string
+	bigQueryLocation        string
+	bigQueryCredentialsFile string
+	trinoCatalog            string
+	trinoHTTPS              bool
+	queries                 []string
+	queryTypes              []string
+	queryNames              []string
+	queryEventTypes         []string
+	eventTypeOverride       string
+	queryTypeEventTypes     map[string]string
+	includeQueryName        bool
+	includeQueryText        bool
+	includeColumnOrder      bool
+	queryTexts              []string
+	queryNullDefaults       []map[string]interface{}
+
+	includeColumns      []string
+	excludeColumns      []string
+	queryIncludeColumns [][]string
+	queryExcludeColumns [][]string
+
+	queryExpectedColumns [][]string
+
+	slaveDelayNullValue   string
+	slaveStatusColumns    map[string]bool
+	columnRenames         map[string]string
+	fieldNameCase         string
+	duplicateColumnPolicy string
+	byteLengthColumns     map[string]bool
+	jsonColumns           map[string]bool
+	binaryColumns         map[string]string
+	epochColumns          map[string]string
+	epochTimestampColumn  string
+	timestampTruncate     time.Duration
+	statusMappings        map[string]map[string]int
+
+	twoColumnsNameIndex    int
+	twoColumnsValueIndex   int
+	deltaWildcard          string
+	queryDeltaWildcards    []string
+	emitRawWithDelta       bool
+	deltaFieldSuffix       string
+	includeDeltaUnit       bool
+	deltaOutputMode        string
+	includeDeltaInterval   bool
+	includeDeltaTimestamps bool
+	floatPrecision         int
+	numberFormat           string
+	deltaSmoothingAlpha    float64
+	deltaMinInterval       time.Duration
+	deltaMaxAge            time.Duration
+	deltaWarmupCycles      int
+	deltaAllowNegative     bool
+	deltaNegativeColumns   map[string]bool
+
+	connectRetries      int
+	connectRetryBackoff time.Duration
+	queryRetries        int
+	queryRetryBackoff   time.Duration
+	concurrency         int
+	resultBufferSize    int
+	batchSize           int
+	maxRowsPerQuery     int
+	maxRowErrors        int
+	maxEventFields      int
+	useColumnTypes      bool
+	multipleResultSets  bool
+	columnTypeOverrides map[string]int
+
+	// disableTypeInference keeps the values that have no ColumnTypes entry as strings
+	disableTypeInference bool
+	multiRowMode         string
+	multiRowArrayKey     string
+	eventLayout          string
+	shardIndex           int
+	shardTotal           int
+
+	// targetSelectors are the target name and tags of this instance, queryTargets select the targets of each query
+	targetSelectors     map[string]bool
+	queryTargets        [][]string
+	publishQueryMetrics bool
+
+	// includeQueryDiagnostics adds the query error and the MySQL warnings count to the sqlbeat-query events
+	includeQueryDiagnostics bool
+	publishTickEvents       bool
+	publishEmptyEvents      bool
+
+	queryTimeouts          []time.Duration
+	queryTimeoutWarnAfter  int
+	queryTimeoutErrorAfter int
+	queryTimeoutAlertAfter int
+	timeoutCounts          *timeoutCounts
+
+	db           *sql.DB
+	dbConnString string
+
+	// pools are the named connection pools, queryPools assigns queries to them
+	connectionPools map[string]int
+	queryPools      []string
+	pools           map[string]*sql.DB
+
+	// databases are the connections to the other databases of the server, queryDatabases assigns queries to them
+	queryDatabases []string
+	databases      map[string]*sql.DB
+
+	// queryOutputParams are the output parameters of the stored-procedure queries and their T-SQL types
+	queryOutputParams []map[string]string
+
+	// queryKeyColumns are the columns the rows of the snapshot-diff queries are keyed by
+	queryKeyColumns []string
+	snapshotState   *snapshotState
+
+	// publishOnChangeOnly are the queries whose events are only published when they differ from the previous cycle
+	publishOnChangeOnly []bool
+	eventHashes         *eventHashState
+
+	// initQueries prepare the session state of every new connection, they aren't published
+	initQueries []string
+
+	// docIDColumn is the column whose value becomes the document id of the query events
+	docIDColumn string
+
+	// tick holds the counters of the current tick
+	tickCount int
+	tick      *tickStats
+
+	// metrics holds the totals of all ticks, served on metricsAddr
+	metricsAddr string
+	metrics     *beatMetrics
+
+	// health tracks the last successful cycle, served on healthAddr, it's unhealthy after healthStaleAfter
+	healthAddr       string
+	health           *beatHealth
+	healthStaleAfter time.Duration
+
+	// debugOutput writes the events to debugOutputFile, opened in Run
+	debugOutputFile string
+	debugOutputOnly bool
+	debugOutput     *debugOutput
+
+	// target identifies the monitored DB, delta columns are stored per target
+	target     string
+	deltaState *deltaState
 }
 
 var (
 	commonIV = []byte{0x00, 0x01, 0x02, 0x03, 0x04, 0x05, 0x06, 0x07, 0x08, 0x09, 0x0a, 0x0b, 0x0c, 0x0d, 0x0e, 0x0f}
+
+	// stringLiteralRegexp matches the SQL string literals, including the ones with escaped quotes ('it''s')
+	stringLiteralRegexp = regexp.MustCompile(`'(?:[^']|'')*'`)
+
+	// errStopped is returned when the beat is stopped while waiting to connect
+	errStopped = errors.New("sqlbeat was stopped")
+
+	// errMaxEventFields is returned when a row isn't added to a two-columns event that reached MaxEventFields
+	errMaxEventFields = errors.New("the event reached MaxEventFields")
+
+	// the fields of the sqlbeat object of the query events
+	eventMetaFields = []string{"query", "query_text", "change", "row_count", "columns"}
+
+	// the supported query types
+	queryTypeNames = []string{queryTypeSingleRow, queryTypeMultipleRows, queryTypeTwoColumns, queryTypeSlaveDelay,
+		queryTypeLongFormat, queryTypeAllSlavesStatus, queryTypeTimeSeries, queryTypeStoredProcedure, queryTypeSnapshotDiff,
+		queryTypePgReplicationLag}
+
+	// column types that can be forced with ColumnTypes
+	columnTypeNames = map[string]int{
+		"string": columnTypeString,
+		"int":    columnTypeInt,
+		"float":  columnTypeFloat,
+		"bool":   columnTypeBool,
+		"money":  columnTypeMoney,
+	}
+
+	// the replication lag and the state of the replication threads
+	defaultSlaveStatusColumns = []string{columnNameSlaveDelay, columnNameSlaveIORunning, columnNameSlaveSQLRunning}
 )
 
 const (
 	// secret length must be 16, 24 or 32, corresponding to the AES-128, AES-192 or AES-256 algorithms
 	// you should compile your sqlbeat with a unique secret and hide it (don't leave it in the code after compiled)
-	// you can encrypt your password with github.com/adibendahan/sqlbeat-password-encrypter just update your secret
-	// (and commonIV if you choose to change it) and compile.
+	// you can encrypt your password with `sqlbeat encrypt-password` once compiled with your secret
+	// (and commonIV if you choose to change it).
 	secret = "github.com/adibendahan/mysqlbeat"
 
 	// supported DB types
-	dbtMySQL = "mysql"
-	dbtMSSQL = "mssql"
-	dbtPSQL  = "postgres"
+	dbtMySQL    = "mysql"
+	dbtMSSQL    = "mssql"
+	dbtPSQL     = "postgres"
+	dbtBigQuery = "bigquery"
+
+	// ClickHouse returns typed values, database/sql converts them to text when scanned into RawBytes
+	dbtClickHouse = "clickhouse"
+
+	// CockroachDB speaks the postgres wire protocol, it's reached through the postgres driver
+	dbtCockroach = "cockroachdb"
+
+	// Trino (formerly PrestoSQL) is queried over HTTP, its typed values are scanned by scanTypedRow
+	dbtTrino = "trino"
 
 	// default values
-	defaultPeriod        = "10s"
-	defaultHostname      = "127.0.0.1"
-	defaultPortMySQL     = "3306"
-	defaultPortMSSQL     = "1433"
-	defaultPortPSQL      = "5432"
-	defaultUsername      = "sqlbeat_user"
-	defaultPassword      = "sqlbeat_pass"
-	defaultDeltaWildcard = "__DELTA"
+	defaultPeriod                = "10s"
+	defaultHostname              = "127.0.0.1"
+	defaultPortMySQL             = "3306"
+	defaultPortMSSQL             = "1433"
+	defaultPortPSQL              = "5432"
+	defaultPortClickHouse        = "9000"
+	defaultPortCockroach         = "26257"
+	defaultPortTrino             = "8080"
+	defaultUsername              = "sqlbeat_user"
+	defaultPassword              = "sqlbeat_pass"
+	defaultDeltaWildcard         = "__DELTA"
+	defaultHealthStaleCycles     = 3
+	deltaWildcardDisabled        = "-"
+	defaultDeltaOutputMode       = deltaOutputModeRate
+	defaultEncryptionMode        = encryptionModeCFB
+	defaultAuthMode              = authModeSQL
+	defaultSSHPort               = "22"
+	defaultSSHKnownHosts         = "~/.ssh/known_hosts"
+	defaultApplicationName       = "sqlbeat"
+	defaultMultiRowMode          = multiRowModePerRow
+	defaultDuplicateColumnPolicy = duplicateColumnPolicySuffix
+	defaultBatchSize             = 100
+	defaultMultiRowArrayKey      = "rows"
+	defaultEventLayout           = eventLayoutFlat
+
+	defaultFloatPrecision       = -1
+	defaultTwoColumnsValueIndex = 1
+
+	defaultQueryTimeoutWarnAfter  = 3
+	defaultQueryTimeoutErrorAfter = 10
+	defaultMaxRowErrors           = 10
+	defaultConnectRetryBackoff    = "1s"
+	defaultQueryRetryBackoff      = "1s"
+	maxConnectRetryBackoff        = time.Minute
+	minDeltaInterval              = time.Millisecond
+	checkCredentialsTimeout       = 10 * time.Second
 
 	// query types values
-	queryTypeSingleRow    = "single-row"
-	queryTypeMultipleRows = "multiple-rows"
-	queryTypeTwoColumns   = "two-columns"
-	queryTypeSlaveDelay   = "show-slave-delay"
+	queryTypeSingleRow        = "single-row"
+	queryTypeMultipleRows     = "multiple-rows"
+	queryTypeTwoColumns       = "two-columns"
+	queryTypeSlaveDelay       = "show-slave-delay"
+	queryTypeLongFormat       = "long-format"
+	queryTypeAllSlavesStatus  = "show-all-slaves-status"
+	queryTypeTimeSeries       = "time-series"
+	queryTypeStoredProcedure  = "stored-procedure"
+	queryTypeSnapshotDiff     = "snapshot-diff"
+	queryTypePgReplicationLag = "pg-replication-lag"
+
+	// field name cases values
+	fieldNameCaseNone  = "none"
+	fieldNameCaseLower = "lower"
+	fieldNameCaseSnake = "snake"
+
+	// delta output modes values
+	deltaOutputModeRate       = "rate"
+	deltaOutputModeIncrement  = "increment"
+	deltaOutputModeCumulative = "cumulative"
+
+	// duplicate column policies values
+	duplicateColumnPolicySuffix    = "suffix"
+	duplicateColumnPolicyError     = "error"
+	duplicateColumnPolicyOverwrite = "overwrite"
+
+	// delta units values, see IncludeDeltaUnit
+	deltaUnitPerSecond   = "per_second"
+	deltaUnitPerInterval = "per_interval"
+
+	// multiple-rows modes values
+	multiRowModePerRow = "per-row"
+	multiRowModeArray  = "array"
+
+	// event layouts values
+	eventLayoutFlat   = "flat"
+	eventLayoutNested = "nested"
+
+	// binary encodings values
+	binaryEncodingHex    = "hex"
+	binaryEncodingBase64 = "base64"
+	binaryEncodingSkip   = "skip"
+
+	// number formats values, the formats are named after how they write one thousand two hundred thirty-four and a half
+	numberFormatStrict      = "strict"
+	numberFormatCommaPeriod = "1,234.5"
+	numberFormatPeriodComma = "1.234,5"
+	numberFormatSpaceComma  = "1 234,5"
+
+	// epoch units values
+	epochUnitSeconds = "seconds"
+	epochUnitMillis  = "millis"
+	epochUnitMicros  = "micros"
+
+	// authentication modes values (token based modes are MSSQL only)
+	authModeSQL     = "sql"
+	authModeAzureAD = "azuread"
+	authModeMSI     = "msi"
+	authModeAWSIAM  = "awsiam"
+
+	// encryption modes values
+	encryptionModeCFB = "cfb"
+	encryptionModeGCM = "gcm"
+
+	// event types values
+	eventTypeAlert        = "sqlbeat-alert"
+	eventTypeQueryMetrics = "sqlbeat-query"
+	eventTypeTick         = "sqlbeat-tick"
+
+	// default event types of the query types
+	eventTypeReplication = "replication"
 
 	// special column names values
-	columnNameSlaveDelay = "Seconds_Behind_Master"
+	columnNameSlaveDelay      = "Seconds_Behind_Master"
+	columnNameConnectionName  = "Connection_name"
+	columnNameSlaveIORunning  = "Slave_IO_Running"
+	columnNameSlaveSQLRunning = "Slave_SQL_Running"
 
 	// column types values
 	columnTypeString = iota
 	columnTypeInt
+	columnTypeUint
 	columnTypeFloat
+	columnTypeBool
+	columnTypeAuto
+	columnTypeMoney
 )
 
 // New Creates beater
 func New() *Sqlbeat {
+	ctx, cancel := context.WithCancel(context.Background())
 	return &Sqlbeat{
-		done: make(chan struct{}),
+		done:      make(chan struct{}),
+		ctx:       ctx,
+		cancel:    cancel,
+		metrics:   &beatMetrics{},
+		health:    &beatHealth{},
+		authToken: &authToken{},
 	}
 }
 
@@ -111,13 +436,21 @@ func (bt *Sqlbeat) Setup(b *beat.Beat) error {
 
 	// Config errors handling
 	switch bt.beatConfig.Sqlbeat.DBType {
-	case dbtMSSQL, dbtMySQL, dbtPSQL:
+	case dbtMSSQL, dbtMySQL, dbtPSQL, dbtBigQuery, dbtClickHouse, dbtCockroach, dbtTrino:
 		break
 	default:
-		err := fmt.Errorf("Unknown DB type, supported DB types: `mssql`, `mysql`, `postgres`")
+		err := fmt.Errorf("Unknown DB type, supported DB types: `mssql`, `mysql`, `postgres`, `bigquery`, `clickhouse`, `cockroachdb`, `trino`")
 		return err
 	}
 
+	// Add the queries of the query catalog
+	if bt.beatConfig.Sqlbeat.QueryCatalog != "" {
+		err := loadQueryCatalog(&bt.beatConfig.Sqlbeat, bt.beatConfig.Sqlbeat.QueryCatalog)
+		if err != nil {
+			return err
+		}
+	}
+
 	if len(bt.beatConfig.Sqlbeat.Queries) < 1 {
 		err := fmt.Errorf("There are no queries to execute")
 		return err
@@ -128,386 +461,2626 @@ func (bt *Sqlbeat) Setup(b *beat.Beat) error {
 		return err
 	}
 
-	if bt.beatConfig.Sqlbeat.DBType == dbtPSQL {
-		if bt.beatConfig.Sqlbeat.Database == "" {
-			err := fmt.Errorf("Database must be selected when using DB type postgres")
+	for index, queryType := range bt.beatConfig.Sqlbeat.QueryTypes {
+		if !isQueryType(queryType) {
+			err := fmt.Errorf("Unknown query type '%v' for query #%d, supported query types: `%v`", queryType, index, strings.Join(queryTypeNames, "`, `"))
 			return err
 		}
-		if bt.beatConfig.Sqlbeat.PostgresSSLMode == "" {
-			err := fmt.Errorf("PostgresSSLMode must be selected when using DB type postgres")
+	}
+
+	for queryType := range bt.beatConfig.Sqlbeat.QueryTypeEventTypes {
+		if !isQueryType(queryType) {
+			err := fmt.Errorf("Unknown query type '%v' in QueryTypeEventTypes, supported query types: `%v`", queryType, strings.Join(queryTypeNames, "`, `"))
 			return err
 		}
 	}
 
-	// Setting defaults for missing config
-	if bt.beatConfig.Sqlbeat.Period == "" {
-		logp.Info("Period not selected, proceeding with '%v' as default", defaultPeriod)
-		bt.beatConfig.Sqlbeat.Period = defaultPeriod
+	// Each entry of the per-query settings corresponds to the query on the same index
+	perQueryLens := []struct {
+		name    string
+		entries int
+	}{
+		{"queryNullDefaults", len(bt.beatConfig.Sqlbeat.QueryNullDefaults)},
+		{"queryTimeouts", len(bt.beatConfig.Sqlbeat.QueryTimeouts)},
+		{"queryEventTypes", len(bt.beatConfig.Sqlbeat.QueryEventTypes)},
+		{"queryDeltaWildcards", len(bt.beatConfig.Sqlbeat.QueryDeltaWildcards)},
+		{"queryNames", len(bt.beatConfig.Sqlbeat.QueryNames)},
+		{"queryIncludeColumns", len(bt.beatConfig.Sqlbeat.QueryIncludeColumns)},
+		{"queryExcludeColumns", len(bt.beatConfig.Sqlbeat.QueryExcludeColumns)},
+		{"queryTargets", len(bt.beatConfig.Sqlbeat.QueryTargets)},
+		{"queryExpectedColumns", len(bt.beatConfig.Sqlbeat.QueryExpectedColumns)},
+		{"queryPools", len(bt.beatConfig.Sqlbeat.QueryPools)},
+		{"queryDatabases", len(bt.beatConfig.Sqlbeat.QueryDatabases)},
+		{"queryOutputParams", len(bt.beatConfig.Sqlbeat.QueryOutputParams)},
+		{"queryKeyColumns", len(bt.beatConfig.Sqlbeat.QueryKeyColumns)},
+		{"publishOnChangeOnly", len(bt.beatConfig.Sqlbeat.PublishOnChangeOnly)},
 	}
-
-	if bt.beatConfig.Sqlbeat.Hostname == "" {
-		logp.Info("Hostname not selected, proceeding with '%v' as default", defaultHostname)
-		bt.beatConfig.Sqlbeat.Hostname = defaultHostname
+	for _, perQueryLen := range perQueryLens {
+		err := bt.checkPerQueryLen(perQueryLen.name, perQueryLen.entries)
+		if err != nil {
+			return err
+		}
 	}
 
-	if bt.beatConfig.Sqlbeat.Port == "" {
-		switch bt.beatConfig.Sqlbeat.DBType {
-		case dbtMSSQL:
-			bt.beatConfig.Sqlbeat.Port = defaultPortMSSQL
-		case dbtMySQL:
-			bt.beatConfig.Sqlbeat.Port = defaultPortMySQL
-		case dbtPSQL:
-			bt.beatConfig.Sqlbeat.Port = defaultPortPSQL
+	queryNames := make(map[string]int)
+	for index, name := range bt.beatConfig.Sqlbeat.QueryNames {
+		if otherIndex, ok := queryNames[name]; name != "" && ok {
+			err := fmt.Errorf("Config file error, queries #%d and #%d have the same name '%v'", otherIndex, index, name)
+			return err
 		}
-		logp.Info("Port not selected, proceeding with '%v' as default", bt.beatConfig.Sqlbeat.Port)
+		queryNames[name] = index
 	}
 
-	if bt.beatConfig.Sqlbeat.Username == "" {
-		logp.Info("Username not selected, proceeding with '%v' as default", defaultUsername)
-		bt.beatConfig.Sqlbeat.Username = defaultUsername
+	if bt.beatConfig.Sqlbeat.TwoColumnsValueIndex == nil {
+		twoColumnsValueIndex := defaultTwoColumnsValueIndex
+		bt.beatConfig.Sqlbeat.TwoColumnsValueIndex = &twoColumnsValueIndex
 	}
 
-	if bt.beatConfig.Sqlbeat.Password == "" && bt.beatConfig.Sqlbeat.EncryptedPassword == "" {
-		logp.Info("Password not selected, proceeding with default password")
-		bt.beatConfig.Sqlbeat.Password = defaultPassword
+	if bt.beatConfig.Sqlbeat.TwoColumnsNameIndex < 0 || *bt.beatConfig.Sqlbeat.TwoColumnsValueIndex < 0 {
+		err := fmt.Errorf("TwoColumnsNameIndex and TwoColumnsValueIndex must be zero or a positive number")
+		return err
 	}
 
-	if bt.beatConfig.Sqlbeat.DeltaWildcard == "" {
-		logp.Info("DeltaWildcard not selected, proceeding with '%v' as default", defaultDeltaWildcard)
-		bt.beatConfig.Sqlbeat.DeltaWildcard = defaultDeltaWildcard
+	if bt.beatConfig.Sqlbeat.TwoColumnsNameIndex == *bt.beatConfig.Sqlbeat.TwoColumnsValueIndex {
+		err := fmt.Errorf("TwoColumnsNameIndex and TwoColumnsValueIndex must be different columns")
+		return err
 	}
 
-	// Parse the Period string
-	var durationParseError error
-	bt.period, durationParseError = time.ParseDuration(bt.beatConfig.Sqlbeat.Period)
-	if durationParseError != nil {
-		return durationParseError
+	// The columns of a two-columns query are known when the query has expected columns
+	for index, queryType := range bt.beatConfig.Sqlbeat.QueryTypes {
+		if queryType != queryTypeTwoColumns || index >= len(bt.beatConfig.Sqlbeat.QueryExpectedColumns) {
+			continue
+		}
+		expectedColumns := bt.beatConfig.Sqlbeat.QueryExpectedColumns[index]
+		if len(expectedColumns) == 0 {
+			continue
+		}
+		if bt.beatConfig.Sqlbeat.TwoColumnsNameIndex >= len(expectedColumns) || *bt.beatConfig.Sqlbeat.TwoColumnsValueIndex >= len(expectedColumns) {
+			err := fmt.Errorf("Query #%d is expected to return %d columns, TwoColumnsNameIndex (%d) and TwoColumnsValueIndex (%d) must be lower than the number of columns",
+				index, len(expectedColumns), bt.beatConfig.Sqlbeat.TwoColumnsNameIndex, *bt.beatConfig.Sqlbeat.TwoColumnsValueIndex)
+			return err
+		}
 	}
 
-	// Handle password decryption and save in the bt
-	if bt.beatConfig.Sqlbeat.Password != "" {
-		bt.password = bt.beatConfig.Sqlbeat.Password
-	} else if bt.beatConfig.Sqlbeat.EncryptedPassword != "" {
-		aesCipher, err := aes.NewCipher([]byte(secret))
-		if err != nil {
+	// Validate the columns filters glob patterns
+	var columnPatterns []string
+	columnPatterns = append(columnPatterns, bt.beatConfig.Sqlbeat.IncludeColumns...)
+	columnPatterns = append(columnPatterns, bt.beatConfig.Sqlbeat.ExcludeColumns...)
+	for _, patterns := range bt.beatConfig.Sqlbeat.QueryIncludeColumns {
+		columnPatterns = append(columnPatterns, patterns...)
+	}
+	for _, patterns := range bt.beatConfig.Sqlbeat.QueryExcludeColumns {
+		columnPatterns = append(columnPatterns, patterns...)
+	}
+	for _, pattern := range columnPatterns {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return fmt.Errorf("Invalid column filter pattern '%v': %v", pattern, err)
+		}
+	}
+
+	if len(bt.beatConfig.Sqlbeat.DSNParams) > 0 {
+		if bt.beatConfig.Sqlbeat.DBType != dbtMySQL {
+			err := fmt.Errorf("DSNParams can only be used with DB type mysql")
 			return err
 		}
-		cfbDecrypter := cipher.NewCFBDecrypter(aesCipher, commonIV)
-		chiperText, err := hex.DecodeString(bt.beatConfig.Sqlbeat.EncryptedPassword)
-		if err != nil {
+		if bt.beatConfig.Sqlbeat.ConnString != "" {
+			err := fmt.Errorf("DSNParams can't be used with ConnString, add the parameters to the ConnString instead")
 			return err
 		}
-		plaintextCopy := make([]byte, len(chiperText))
-		cfbDecrypter.XORKeyStream(plaintextCopy, chiperText)
-		bt.password = string(plaintextCopy)
 	}
 
-	// init the oldValues and oldValuesAge array
-	bt.oldValues = common.MapStr{"sqlbeat": "init"}
-	bt.oldValuesAge = common.MapStr{"sqlbeat": "init"}
-
-	// Save config values to the bt
-	bt.dbType = bt.beatConfig.Sqlbeat.DBType
-	bt.hostname = bt.beatConfig.Sqlbeat.Hostname
-	bt.port = bt.beatConfig.Sqlbeat.Port
-	bt.username = bt.beatConfig.Sqlbeat.Username
-	bt.database = bt.beatConfig.Sqlbeat.Database
-	bt.postgresSSLMode = bt.beatConfig.Sqlbeat.PostgresSSLMode
-	bt.queries = bt.beatConfig.Sqlbeat.Queries
-	bt.queryTypes = bt.beatConfig.Sqlbeat.QueryTypes
-	bt.deltaWildcard = bt.beatConfig.Sqlbeat.DeltaWildcard
-
-	logp.Info("Total # of queries to execute: %d", len(bt.queries))
-	for index, queryStr := range bt.queries {
-		logp.Info("Query #%d (type: %s): %s", index+1, bt.queryTypes[index], queryStr)
+	// The driver-level timeouts of the MySQL connection, each one is a DSN parameter
+	mysqlDriverParams := map[string]bool{
+		"timeout":           bt.beatConfig.Sqlbeat.MySQLTimeout != "",
+		"readTimeout":       bt.beatConfig.Sqlbeat.MySQLReadTimeout != "",
+		"writeTimeout":      bt.beatConfig.Sqlbeat.MySQLWriteTimeout != "",
+		"interpolateParams": bt.beatConfig.Sqlbeat.MySQLInterpolateParams,
+	}
+	for name, isSet := range mysqlDriverParams {
+		if !isSet {
+			continue
+		}
+		if bt.beatConfig.Sqlbeat.DBType != dbtMySQL {
+			err := fmt.Errorf("MySQLTimeout, MySQLReadTimeout, MySQLWriteTimeout and MySQLInterpolateParams can only be used with DB type mysql")
+			return err
+		}
+		if bt.beatConfig.Sqlbeat.ConnString != "" {
+			err := fmt.Errorf("MySQLTimeout, MySQLReadTimeout, MySQLWriteTimeout and MySQLInterpolateParams can't be used with ConnString, add the parameters to the ConnString instead")
+			return err
+		}
+		if _, ok := bt.beatConfig.Sqlbeat.DSNParams[name]; ok {
+			err := fmt.Errorf("DSNParams %v is also set by its own option, remove it from DSNParams", name)
+			return err
+		}
 	}
 
-	return nil
-}
-
-// Run is a functions that runs the beat
-func (bt *Sqlbeat) Run(b *beat.Beat) error {
-	logp.Info("sqlbeat is running! Hit CTRL-C to stop it.")
+	for index, query := range bt.beatConfig.Sqlbeat.InitQueries {
+		if strings.TrimSpace(query) == "" {
+			err := fmt.Errorf("Config file error, initQueries entry #%d is empty", index)
+			return err
+		}
+	}
 
-	ticker := time.NewTicker(bt.period)
-	for {
-		select {
-		case <-bt.done:
-			return nil
-		case <-ticker.C:
+	for index, database := range bt.beatConfig.Sqlbeat.QueryDatabases {
+		if database == "" {
+			continue
+		}
+		if bt.beatConfig.Sqlbeat.ConnString != "" || bt.beatConfig.Sqlbeat.DBType == dbtBigQuery {
+			err := fmt.Errorf("QueryDatabases can't be used with ConnString or with BigQuery, the database of the connection can't be switched")
+			return err
+		}
+		if index < len(bt.beatConfig.Sqlbeat.QueryPools) && bt.beatConfig.Sqlbeat.QueryPools[index] != "" {
+			err := fmt.Errorf("Query #%d is assigned to both a connection pool and a database, only one of them can be used", index)
+			return err
 		}
+	}
 
-		err := bt.beat(b)
-		if err != nil {
+	for name, maxOpenConns := range bt.beatConfig.Sqlbeat.ConnectionPools {
+		if maxOpenConns < 1 {
+			err := fmt.Errorf("Connection pool '%v' must allow at least 1 open connection", name)
 			return err
 		}
 	}
-}
 
-// Cleanup is a function that does nothing on this beat :)
-func (bt *Sqlbeat) Cleanup(b *beat.Beat) error {
-	return nil
-}
+	for index, name := range bt.beatConfig.Sqlbeat.QueryPools {
+		if _, ok := bt.beatConfig.Sqlbeat.ConnectionPools[name]; name != "" && !ok {
+			err := fmt.Errorf("Query #%d is assigned to the undefined connection pool '%v'", index, name)
+			return err
+		}
+	}
 
-// Stop is a function that runs once the beat is stopped
-func (bt *Sqlbeat) Stop() {
-	close(bt.done)
-}
+	if bt.beatConfig.Sqlbeat.ShardTotal < 0 {
+		err := fmt.Errorf("ShardTotal must be zero (sharding disabled) or a positive number")
+		return err
+	}
 
-///*** sqlbeat methods ***///
+	if bt.beatConfig.Sqlbeat.ShardTotal > 0 &&
+		(bt.beatConfig.Sqlbeat.ShardIndex < 0 || bt.beatConfig.Sqlbeat.ShardIndex >= bt.beatConfig.Sqlbeat.ShardTotal) {
+		err := fmt.Errorf("ShardIndex must be between 0 and ShardTotal-1 (%d)", bt.beatConfig.Sqlbeat.ShardTotal-1)
+		return err
+	}
 
-// beat is a function that iterate over the query array, generate and publish events
-func (bt *Sqlbeat) beat(b *beat.Beat) error {
+	if bt.beatConfig.Sqlbeat.ResultBufferSize < 0 {
+		err := fmt.Errorf("ResultBufferSize must be zero or a positive number")
+		return err
+	}
 
-	connString := ""
+	switch bt.beatConfig.Sqlbeat.FieldNameCase {
+	case "", fieldNameCaseNone, fieldNameCaseLower, fieldNameCaseSnake:
+		break
+	default:
+		err := fmt.Errorf("Unknown FieldNameCase, supported cases: `none`, `lower`, `snake`")
+		return err
+	}
 
-	switch bt.dbType {
-	case dbtMSSQL:
-		connString = fmt.Sprintf("server=%v;user id=%v;password=%v;port=%v;database=%v",
-			bt.hostname, bt.username, bt.password, bt.port, bt.database)
+	switch bt.beatConfig.Sqlbeat.DuplicateColumnPolicy {
+	case "":
+		bt.beatConfig.Sqlbeat.DuplicateColumnPolicy = defaultDuplicateColumnPolicy
+		logp.Info("DuplicateColumnPolicy not selected, proceeding with '%v' as default", defaultDuplicateColumnPolicy)
+	case duplicateColumnPolicySuffix, duplicateColumnPolicyError, duplicateColumnPolicyOverwrite:
+		break
+	default:
+		err := fmt.Errorf("Unknown DuplicateColumnPolicy, supported policies: `suffix`, `error`, `overwrite`")
+		return err
+	}
 
-	case dbtMySQL:
-		connString = fmt.Sprintf("%v:%v@tcp(%v:%v)/%v",
-			bt.username, bt.password, bt.hostname, bt.port, bt.database)
+	// A query expected to return duplicate columns would fail on every cycle with the error policy
+	if bt.beatConfig.Sqlbeat.DuplicateColumnPolicy == duplicateColumnPolicyError {
+		for index, expectedColumns := range bt.beatConfig.Sqlbeat.QueryExpectedColumns {
+			if duplicates := duplicateColumns(expectedColumns); len(duplicates) > 0 {
+				err := fmt.Errorf("Query #%d expected columns have duplicates %v, which DuplicateColumnPolicy error rejects", index, duplicates)
+				return err
+			}
+		}
+	}
 
-	case dbtPSQL:
-		connString = fmt.Sprintf("%v://%v:%v@%v:%v/%v?sslmode=%v",
-			dbtPSQL, bt.username, bt.password, bt.hostname, bt.port, bt.database, bt.postgresSSLMode)
+	switch bt.beatConfig.Sqlbeat.DeltaOutputMode {
+	case "", deltaOutputModeRate, deltaOutputModeIncrement, deltaOutputModeCumulative:
+		break
+	default:
+		err := fmt.Errorf("Unknown DeltaOutputMode, supported modes: `rate`, `increment`, `cumulative`")
+		return err
 	}
 
-	db, err := sql.Open(bt.dbType, connString)
-	if err != nil {
+	if bt.beatConfig.Sqlbeat.DeltaSmoothingAlpha < 0 || bt.beatConfig.Sqlbeat.DeltaSmoothingAlpha > 1 {
+		err := fmt.Errorf("DeltaSmoothingAlpha must be between 0 and 1")
 		return err
 	}
-	defer db.Close()
 
-	// Create a two-columns event for later use
-	var twoColumnEvent common.MapStr
+	for strColName, encoding := range bt.beatConfig.Sqlbeat.BinaryColumns {
+		switch encoding {
+		case binaryEncodingHex, binaryEncodingBase64, binaryEncodingSkip:
+			break
+		default:
+			err := fmt.Errorf("Unknown binary encoding '%v' for column '%v', supported binary encodings: `%v`, `%v`, `%v`",
+				encoding, strColName, binaryEncodingHex, binaryEncodingBase64, binaryEncodingSkip)
+			return err
+		}
+	}
 
-LoopQueries:
-	for index, queryStr := range bt.queries {
-		// Log the query run time and run the query
-		dtNow := time.Now()
-		rows, err := db.Query(queryStr)
-		if err != nil {
+	switch bt.beatConfig.Sqlbeat.NumberFormat {
+	case "", numberFormatStrict, numberFormatCommaPeriod, numberFormatPeriodComma, numberFormatSpaceComma:
+		break
+	default:
+		err := fmt.Errorf("Unknown NumberFormat, supported formats: `%v`, `%v`, `%v`, `%v`",
+			numberFormatStrict, numberFormatCommaPeriod, numberFormatPeriodComma, numberFormatSpaceComma)
+		return err
+	}
+
+	for strColName, unit := range bt.beatConfig.Sqlbeat.EpochColumns {
+		switch unit {
+		case epochUnitSeconds, epochUnitMillis, epochUnitMicros:
+			break
+		default:
+			err := fmt.Errorf("Unknown epoch unit '%v' for column '%v', supported epoch units: `%v`, `%v`, `%v`",
+				unit, strColName, epochUnitSeconds, epochUnitMillis, epochUnitMicros)
 			return err
 		}
+	}
 
-		// Populate columns array
-		columns, err := rows.Columns()
-		if err != nil {
+	if bt.beatConfig.Sqlbeat.EpochTimestampColumn != "" {
+		if _, ok := bt.beatConfig.Sqlbeat.EpochColumns[bt.beatConfig.Sqlbeat.EpochTimestampColumn]; !ok {
+			err := fmt.Errorf("EpochTimestampColumn '%v' isn't one of the EpochColumns", bt.beatConfig.Sqlbeat.EpochTimestampColumn)
 			return err
 		}
+	}
 
-		// Populate the two-columns event
-		if bt.queryTypes[index] == queryTypeTwoColumns {
-			twoColumnEvent = common.MapStr{
-				"@timestamp": common.Time(dtNow),
-				"type":       bt.dbType,
-			}
+	for strColName, columnTypeName := range bt.beatConfig.Sqlbeat.ColumnTypes {
+		if _, ok := columnTypeNames[columnTypeName]; !ok {
+			err := fmt.Errorf("Unknown column type '%v' for column '%v', supported column types: `string`, `int`, `float`, `bool`, `money`",
+				columnTypeName, strColName)
+			return err
 		}
+	}
+
+	if bt.beatConfig.Sqlbeat.BatchSize < 0 {
+		err := fmt.Errorf("BatchSize must be zero or a positive number")
+		return err
+	}
 
-	LoopRows:
-		for rows.Next() {
+	if bt.beatConfig.Sqlbeat.MaxRowsPerQuery < 0 {
+		err := fmt.Errorf("MaxRowsPerQuery must be zero or a positive number")
+		return err
+	}
 
-			switch bt.queryTypes[index] {
-			case queryTypeSingleRow, queryTypeSlaveDelay:
-				// Generate an event from the current row
-				event, err := bt.generateEventFromRow(rows, columns, bt.queryTypes[index], dtNow)
+	if bt.beatConfig.Sqlbeat.QueryTextMaxLength < 0 {
+		err := fmt.Errorf("QueryTextMaxLength must be zero or a positive number")
+		return err
+	}
 
-				if err != nil {
-					logp.Err("Query #%v error generating event from rows: %v", index, err)
-				} else if event != nil {
-					b.Events.PublishEvent(event)
-					logp.Info("%v event sent", bt.queryTypes[index])
-				}
-				// breaking after the first row
-				break LoopRows
+	if bt.beatConfig.Sqlbeat.MaxEventFields < 0 {
+		err := fmt.Errorf("MaxEventFields must be zero or a positive number")
+		return err
+	}
 
-			case queryTypeMultipleRows:
-				// Generate an event from the current row
-				event, err := bt.generateEventFromRow(rows, columns, bt.queryTypes[index], dtNow)
+	if bt.beatConfig.Sqlbeat.DebugOutputOnly && bt.beatConfig.Sqlbeat.DebugOutputFile == "" {
+		err := fmt.Errorf("DebugOutputOnly requires a DebugOutputFile to write the events to")
+		return err
+	}
 
-				if err != nil {
-					logp.Err("Query #%v error generating event from rows: %v", index, err)
-					break LoopRows
-				} else if event != nil {
-					b.Events.PublishEvent(event)
-					logp.Info("%v event sent", bt.queryTypes[index])
-				}
+	if bt.beatConfig.Sqlbeat.IncludeQueryDiagnostics && !bt.beatConfig.Sqlbeat.PublishQueryMetrics {
+		err := fmt.Errorf("IncludeQueryDiagnostics adds its fields to the sqlbeat-query events, PublishQueryMetrics must be enabled")
+		return err
+	}
 
-				// Move to the next row
-				continue LoopRows
+	if bt.beatConfig.Sqlbeat.DeltaWarmupCycles < 0 {
+		err := fmt.Errorf("DeltaWarmupCycles must be zero or a positive number")
+		return err
+	}
 
-			case queryTypeTwoColumns:
-				// append current row to the two-columns event
-				err := bt.appendRowToEvent(twoColumnEvent, rows, columns, dtNow)
+	if bt.beatConfig.Sqlbeat.QueryRetries < 0 {
+		err := fmt.Errorf("QueryRetries must be zero or a positive number")
+		return err
+	}
 
-				if err != nil {
-					logp.Err("Query #%v error appending two-columns event: %v", index, err)
-					break LoopRows
-				}
+	if bt.beatConfig.Sqlbeat.ConnectRetries < 0 {
+		err := fmt.Errorf("ConnectRetries must be zero or a positive number")
+		return err
+	}
 
-				// Move to the next row
-				continue LoopRows
+	// A connection string replaces the individual connection fields, they can't be combined
+	if bt.beatConfig.Sqlbeat.ConnString != "" {
+		connFields := []struct {
+			name  string
+			value string
+		}{
+			{"Hostname", bt.beatConfig.Sqlbeat.Hostname},
+			{"Port", bt.beatConfig.Sqlbeat.Port},
+			{"Username", bt.beatConfig.Sqlbeat.Username},
+			{"Password", bt.beatConfig.Sqlbeat.Password},
+			{"EncryptedPassword", bt.beatConfig.Sqlbeat.EncryptedPassword},
+			{"Database", bt.beatConfig.Sqlbeat.Database},
+			{"PostgresSSLMode", bt.beatConfig.Sqlbeat.PostgresSSLMode},
+			{"PostgresSSLCert", bt.beatConfig.Sqlbeat.PostgresSSLCert},
+			{"PostgresSSLKey", bt.beatConfig.Sqlbeat.PostgresSSLKey},
+			{"PostgresSSLRootCert", bt.beatConfig.Sqlbeat.PostgresSSLRootCert},
+			{"BigQueryProject", bt.beatConfig.Sqlbeat.BigQueryProject},
+			{"BigQueryDataset", bt.beatConfig.Sqlbeat.BigQueryDataset},
+			{"BigQueryLocation", bt.beatConfig.Sqlbeat.BigQueryLocation},
+			{"BigQueryCredentialsFile", bt.beatConfig.Sqlbeat.BigQueryCredentialsFile},
+		}
+		for _, field := range connFields {
+			if field.value != "" {
+				err := fmt.Errorf("ConnString can't be combined with %v, set it in the connection string instead", field.name)
+				return err
 			}
 		}
-
-		// If the two-columns event has data, publish it
-		if bt.queryTypes[index] == queryTypeTwoColumns && len(twoColumnEvent) > 2 {
-			b.Events.PublishEvent(twoColumnEvent)
-			logp.Info("%v event sent", queryTypeTwoColumns)
-			twoColumnEvent = nil
+		if len(bt.beatConfig.Sqlbeat.Hostnames) > 0 {
+			err := fmt.Errorf("ConnString can't be combined with Hostnames, set it in the connection string instead")
+			return err
 		}
+	}
 
-		rows.Close()
-		if err = rows.Err(); err != nil {
-			logp.Err("Query #%v error closing rows: %v", index, err)
-			continue LoopQueries
+	// The first of the Hostnames is the active host until it fails
+	if len(bt.beatConfig.Sqlbeat.Hostnames) > 0 {
+		if bt.beatConfig.Sqlbeat.Hostname != "" {
+			err := fmt.Errorf("Hostname and Hostnames can't be combined, add the Hostname to the Hostnames")
+			return err
+		}
+		if bt.beatConfig.Sqlbeat.DBType == dbtBigQuery {
+			err := fmt.Errorf("Hostnames can't be used with DB type bigquery")
+			return err
 		}
+		bt.beatConfig.Sqlbeat.Hostname = bt.beatConfig.Sqlbeat.Hostnames[0]
 	}
 
-	// Great success!
-	return nil
-}
-
-// appendRowToEvent appends the two-column event the current row data
-func (bt *Sqlbeat) appendRowToEvent(event common.MapStr, row *sql.Rows, columns []string, rowAge time.Time) error {
+	if bt.beatConfig.Sqlbeat.ConnString == "" && (bt.beatConfig.Sqlbeat.DBType == dbtPSQL || bt.beatConfig.Sqlbeat.DBType == dbtCockroach) {
+		if bt.beatConfig.Sqlbeat.Database == "" {
+			err := fmt.Errorf("Database must be selected when using DB type %v", bt.beatConfig.Sqlbeat.DBType)
+			return err
+		}
+		if bt.beatConfig.Sqlbeat.PostgresSSLMode == "" {
+			err := fmt.Errorf("PostgresSSLMode must be selected when using DB type %v", bt.beatConfig.Sqlbeat.DBType)
+			return err
+		}
 
-	// Make a slice for the values
-	values := make([]sql.RawBytes, len(columns))
+		// A missing certificate file would otherwise only fail the first connection
+		sslFiles := []struct {
+			name string
+			path string
+		}{
+			{"PostgresSSLCert", bt.beatConfig.Sqlbeat.PostgresSSLCert},
+			{"PostgresSSLKey", bt.beatConfig.Sqlbeat.PostgresSSLKey},
+			{"PostgresSSLRootCert", bt.beatConfig.Sqlbeat.PostgresSSLRootCert},
+		}
+		for _, sslFile := range sslFiles {
+			if sslFile.path == "" {
+				continue
+			}
+			if _, err := os.Stat(sslFile.path); err != nil {
+				err := fmt.Errorf("%v file %v can't be read: %v", sslFile.name, sslFile.path, err)
+				return err
+			}
+		}
 
-	// Copy the references into such a []interface{} for row.Scan
-	scanArgs := make([]interface{}, len(values))
-	for i := range values {
-		scanArgs[i] = &values[i]
+		// The server certificate can only be verified against a root certificate
+		sslMode := bt.beatConfig.Sqlbeat.PostgresSSLMode
+		if (sslMode == "verify-ca" || sslMode == "verify-full") && bt.beatConfig.Sqlbeat.PostgresSSLRootCert == "" {
+			logp.Warn("PostgresSSLMode is %v but PostgresSSLRootCert isn't set, the driver's default root certificate (~/.postgresql/root.crt) is used", sslMode)
+		}
 	}
 
-	// Get RawBytes from data
-	err := row.Scan(scanArgs...)
-	if err != nil {
-		return err
+	// The stored-procedure batch declares T-SQL variables
+	for index, queryType := range bt.beatConfig.Sqlbeat.QueryTypes {
+		if queryType == queryTypeStoredProcedure && bt.beatConfig.Sqlbeat.DBType != dbtMSSQL {
+			err := fmt.Errorf("Query #%d type %v is MSSQL only and can't be used with DB type %v", index, queryType, bt.beatConfig.Sqlbeat.DBType)
+			return err
+		}
 	}
 
-	// First column is the name, second is the value
-	strColName := string(values[0])
-	strColValue := string(values[1])
-	strColType := columnTypeString
-
-	// Try to parse the value to an int64
-	nColValue, err := strconv.ParseInt(strColValue, 0, 64)
-	if err == nil {
-		strColType = columnTypeInt
+	for index, outputParams := range bt.beatConfig.Sqlbeat.QueryOutputParams {
+		if len(outputParams) > 0 && bt.beatConfig.Sqlbeat.QueryTypes[index] != queryTypeStoredProcedure {
+			err := fmt.Errorf("Query #%d has output parameters but isn't a stored-procedure query", index)
+			return err
+		}
+		err := validateOutputParams(index, outputParams)
+		if err != nil {
+			return err
+		}
 	}
 
-	// Try to parse the value to a float64
-	fColValue, err := strconv.ParseFloat(strColValue, 64)
-	if err == nil {
-		// If it's not already an established int64, set type to float
-		if strColType == columnTypeString {
-			strColType = columnTypeFloat
+	for index, queryType := range bt.beatConfig.Sqlbeat.QueryTypes {
+		hasKeyColumn := index < len(bt.beatConfig.Sqlbeat.QueryKeyColumns) && bt.beatConfig.Sqlbeat.QueryKeyColumns[index] != ""
+		if queryType == queryTypeSnapshotDiff && !hasKeyColumn {
+			err := fmt.Errorf("Query #%d type %v must have a key column in queryKeyColumns", index, queryType)
+			return err
 		}
 	}
 
-	// If the column name ends with the deltaWildcard
-	if strings.HasSuffix(strColName, bt.deltaWildcard) {
-		var exists bool
-		_, exists = bt.oldValues[strColName]
-
-		// If an older value doesn't exist
-		if !exists {
-			// Save the current value in the oldValues array
-			bt.oldValuesAge[strColName] = rowAge
-
-			if strColType == columnTypeString {
-				bt.oldValues[strColName] = strColValue
-			} else if strColType == columnTypeInt {
-				bt.oldValues[strColName] = nColValue
-			} else if strColType == columnTypeFloat {
-				bt.oldValues[strColName] = fColValue
-			}
-		} else {
-			// If found the old value's age
-			if dtOldAge, ok := bt.oldValuesAge[strColName].(time.Time); ok {
-				delta := rowAge.Sub(dtOldAge)
-
-				if strColType == columnTypeInt {
-					var calcVal int64
-
-					// Get old value
-					oldVal, _ := bt.oldValues[strColName].(int64)
-					if nColValue > oldVal {
-						// Calculate the delta
-						devResult := float64((nColValue - oldVal)) / float64(delta.Seconds())
-						// Round the calculated result back to an int64
-						calcVal = roundF2I(devResult, .5)
-					} else {
-						calcVal = 0
-					}
-
-					// Add the delta value to the event
-					event[strColName] = calcVal
-
-					// Save current values as old values
-					bt.oldValues[strColName] = nColValue
-					bt.oldValuesAge[strColName] = rowAge
-				} else if strColType == columnTypeFloat {
-					var calcVal float64
-
-					// Get old value
-					oldVal, _ := bt.oldValues[strColName].(float64)
-					if fColValue > oldVal {
-						// Calculate the delta
-						calcVal = (fColValue - oldVal) / float64(delta.Seconds())
-					} else {
-						calcVal = 0
-					}
-
-					// Add the delta value to the event
-					event[strColName] = calcVal
-
-					// Save current values as old values
-					bt.oldValues[strColName] = fColValue
-					bt.oldValuesAge[strColName] = rowAge
-				} else {
-					event[strColName] = strColValue
-				}
-			}
+	// The pg-replication-lag standby query reads the PostgreSQL WAL functions
+	for index, queryType := range bt.beatConfig.Sqlbeat.QueryTypes {
+		if queryType != queryTypePgReplicationLag {
+			continue
 		}
-	} else { // Not a delta column, add the value to the event as is
-		if strColType == columnTypeString {
-			event[strColName] = strColValue
-		} else if strColType == columnTypeInt {
-			event[strColName] = nColValue
-		} else if strColType == columnTypeFloat {
-			event[strColName] = fColValue
+		if bt.beatConfig.Sqlbeat.DBType != dbtPSQL {
+			err := fmt.Errorf("Query #%d type %v is PostgreSQL only and can't be used with DB type %v", index, queryType, bt.beatConfig.Sqlbeat.DBType)
+			return err
+		}
+		if strings.TrimSpace(bt.beatConfig.Sqlbeat.Queries[index]) != "" {
+			logp.Warn("Query #%d type %v runs the standby replication lag query, its configured query is ignored", index, queryType)
 		}
 	}
 
-	// Great success!
+	// CockroachDB has no SHOW SLAVE STATUS, replication is internal to the cluster
+	if bt.beatConfig.Sqlbeat.DBType == dbtCockroach {
+		for index, queryType := range bt.beatConfig.Sqlbeat.QueryTypes {
+			if queryType == queryTypeSlaveDelay || queryType == queryTypeAllSlavesStatus {
+				err := fmt.Errorf("Query #%d type %v is MySQL only and can't be used with DB type cockroachdb", index, queryType)
+				return err
+			}
+		}
+	}
+
+	if bt.beatConfig.Sqlbeat.ConnString == "" && bt.beatConfig.Sqlbeat.DBType == dbtBigQuery {
+		if bt.beatConfig.Sqlbeat.BigQueryProject == "" {
+			err := fmt.Errorf("BigQueryProject must be selected when using DB type bigquery")
+			return err
+		}
+		if bt.beatConfig.Sqlbeat.BigQueryDataset == "" {
+			err := fmt.Errorf("BigQueryDataset must be selected when using DB type bigquery")
+			return err
+		}
+		if bt.beatConfig.Sqlbeat.BigQueryCredentialsFile == "" && os.Getenv("GOOGLE_APPLICATION_CREDENTIALS") == "" {
+			err := fmt.Errorf("BigQueryCredentialsFile must be selected (or GOOGLE_APPLICATION_CREDENTIALS set) when using DB type bigquery")
+			return err
+		}
+	}
+
+	if bt.beatConfig.Sqlbeat.ConnString == "" && bt.beatConfig.Sqlbeat.DBType == dbtTrino {
+		if bt.beatConfig.Sqlbeat.TrinoCatalog == "" {
+			err := fmt.Errorf("TrinoCatalog must be selected when using DB type trino")
+			return err
+		}
+		// Trino refuses passwords sent over plain HTTP
+		hasPassword := bt.beatConfig.Sqlbeat.Password != "" || bt.beatConfig.Sqlbeat.EncryptedPassword != ""
+		if hasPassword && !bt.beatConfig.Sqlbeat.TrinoHTTPS {
+			err := fmt.Errorf("Trino only accepts a password over HTTPS, TrinoHTTPS must be enabled when a password is set")
+			return err
+		}
+	}
+
+	// Setting defaults for missing config
+	if bt.beatConfig.Sqlbeat.Period == "" {
+		logp.Info("Period not selected, proceeding with '%v' as default", defaultPeriod)
+		bt.beatConfig.Sqlbeat.Period = defaultPeriod
+	}
+
+	// The connection string holds the connection fields, they have no defaults
+	if bt.beatConfig.Sqlbeat.Hostname == "" && bt.beatConfig.Sqlbeat.ConnString == "" {
+		logp.Info("Hostname not selected, proceeding with '%v' as default", defaultHostname)
+		bt.beatConfig.Sqlbeat.Hostname = defaultHostname
+	}
+
+	// BigQuery is reached through its API, it has no port
+	if bt.beatConfig.Sqlbeat.Port == "" && bt.beatConfig.Sqlbeat.DBType != dbtBigQuery && bt.beatConfig.Sqlbeat.ConnString == "" {
+		switch bt.beatConfig.Sqlbeat.DBType {
+		case dbtMSSQL:
+			bt.beatConfig.Sqlbeat.Port = defaultPortMSSQL
+		case dbtMySQL:
+			bt.beatConfig.Sqlbeat.Port = defaultPortMySQL
+		case dbtPSQL:
+			bt.beatConfig.Sqlbeat.Port = defaultPortPSQL
+		case dbtClickHouse:
+			bt.beatConfig.Sqlbeat.Port = defaultPortClickHouse
+		case dbtCockroach:
+			bt.beatConfig.Sqlbeat.Port = defaultPortCockroach
+		case dbtTrino:
+			bt.beatConfig.Sqlbeat.Port = defaultPortTrino
+		}
+		logp.Info("Port not selected, proceeding with '%v' as default", bt.beatConfig.Sqlbeat.Port)
+	}
+
+	if bt.beatConfig.Sqlbeat.AuthMode == "" {
+		logp.Info("AuthMode not selected, proceeding with '%v' as default", defaultAuthMode)
+		bt.beatConfig.Sqlbeat.AuthMode = defaultAuthMode
+	}
+
+	// Azure AD and RDS IAM tokens replace the password
+	switch bt.beatConfig.Sqlbeat.AuthMode {
+	case authModeSQL:
+		break
+	case authModeAzureAD, authModeMSI:
+		if bt.beatConfig.Sqlbeat.DBType != dbtMSSQL {
+			err := fmt.Errorf("AuthMode %v is only supported with DB type mssql", bt.beatConfig.Sqlbeat.AuthMode)
+			return err
+		}
+		if bt.beatConfig.Sqlbeat.Password != "" || bt.beatConfig.Sqlbeat.EncryptedPassword != "" {
+			logp.Warn("AuthMode is %v, the configured password is ignored", bt.beatConfig.Sqlbeat.AuthMode)
+		}
+	case authModeAWSIAM:
+		if bt.beatConfig.Sqlbeat.DBType != dbtMySQL && bt.beatConfig.Sqlbeat.DBType != dbtPSQL {
+			err := fmt.Errorf("AuthMode %v is only supported with DB types mysql and postgres", bt.beatConfig.Sqlbeat.AuthMode)
+			return err
+		}
+		if bt.beatConfig.Sqlbeat.ConnString != "" {
+			err := fmt.Errorf("AuthMode %v can't be used with ConnString, the token is added to the connection sqlbeat builds", bt.beatConfig.Sqlbeat.AuthMode)
+			return err
+		}
+		if bt.beatConfig.Sqlbeat.Username == "" {
+			err := fmt.Errorf("Username (the IAM enabled DB user) must be selected when using AuthMode %v", bt.beatConfig.Sqlbeat.AuthMode)
+			return err
+		}
+		if bt.beatConfig.Sqlbeat.AWSRegion == "" {
+			bt.beatConfig.Sqlbeat.AWSRegion = os.Getenv("AWS_REGION")
+		}
+		if bt.beatConfig.Sqlbeat.AWSRegion == "" {
+			err := fmt.Errorf("AWSRegion (or AWS_REGION) must be selected when using AuthMode %v", bt.beatConfig.Sqlbeat.AuthMode)
+			return err
+		}
+		if bt.beatConfig.Sqlbeat.Password != "" || bt.beatConfig.Sqlbeat.EncryptedPassword != "" {
+			logp.Warn("AuthMode is %v, the configured password is ignored", bt.beatConfig.Sqlbeat.AuthMode)
+		}
+	default:
+		err := fmt.Errorf("Unknown AuthMode, supported modes: `%v`, `%v`, `%v`, `%v`", authModeSQL, authModeAzureAD, authModeMSI, authModeAWSIAM)
+		return err
+	}
+	tokenAuth := bt.beatConfig.Sqlbeat.AuthMode != authModeSQL
+
+	if bt.beatConfig.Sqlbeat.Username == "" && bt.beatConfig.Sqlbeat.ConnString == "" && !tokenAuth {
+		logp.Info("Username not selected, proceeding with '%v' as default", defaultUsername)
+		bt.beatConfig.Sqlbeat.Username = defaultUsername
+	}
+
+	// Trino users don't need a password
+	if bt.beatConfig.Sqlbeat.Password == "" && bt.beatConfig.Sqlbeat.EncryptedPassword == "" && bt.beatConfig.Sqlbeat.ConnString == "" && !tokenAuth &&
+		bt.beatConfig.Sqlbeat.DBType != dbtTrino {
+		logp.Info("Password not selected, proceeding with default password")
+		bt.beatConfig.Sqlbeat.Password = defaultPassword
+	}
+
+	if bt.beatConfig.Sqlbeat.DeltaWildcard == "" {
+		logp.Info("DeltaWildcard not selected, proceeding with '%v' as default", defaultDeltaWildcard)
+		bt.beatConfig.Sqlbeat.DeltaWildcard = defaultDeltaWildcard
+	}
+
+	// Identify the connections of this instance on the DB server
+	if bt.beatConfig.Sqlbeat.ApplicationName == "" {
+		applicationName := defaultApplicationName
+		if hostname, err := os.Hostname(); err == nil {
+			applicationName += "@" + hostname
+		}
+		logp.Info("ApplicationName not selected, proceeding with '%v' as default", applicationName)
+		bt.beatConfig.Sqlbeat.ApplicationName = applicationName
+	}
+
+	if bt.beatConfig.Sqlbeat.BatchSize == 0 {
+		logp.Info("BatchSize not selected, proceeding with '%v' as default", defaultBatchSize)
+		bt.beatConfig.Sqlbeat.BatchSize = defaultBatchSize
+	}
+
+	if bt.beatConfig.Sqlbeat.MultiRowMode == "" {
+		logp.Info("MultiRowMode not selected, proceeding with '%v' as default", defaultMultiRowMode)
+		bt.beatConfig.Sqlbeat.MultiRowMode = defaultMultiRowMode
+	}
+
+	switch bt.beatConfig.Sqlbeat.MultiRowMode {
+	case multiRowModePerRow, multiRowModeArray:
+		break
+	default:
+		err := fmt.Errorf("Unknown multiple-rows mode, supported multiple-rows modes: `%v`, `%v`", multiRowModePerRow, multiRowModeArray)
+		return err
+	}
+
+	if bt.beatConfig.Sqlbeat.MultiRowArrayKey == "" {
+		logp.Info("MultiRowArrayKey not selected, proceeding with '%v' as default", defaultMultiRowArrayKey)
+		bt.beatConfig.Sqlbeat.MultiRowArrayKey = defaultMultiRowArrayKey
+	}
+
+	if bt.beatConfig.Sqlbeat.EventLayout == "" {
+		logp.Info("EventLayout not selected, proceeding with '%v' as default", defaultEventLayout)
+		bt.beatConfig.Sqlbeat.EventLayout = defaultEventLayout
+	}
+
+	switch bt.beatConfig.Sqlbeat.EventLayout {
+	case eventLayoutFlat, eventLayoutNested:
+		break
+	default:
+		err := fmt.Errorf("Unknown EventLayout, supported layouts: `%v`, `%v`", eventLayoutFlat, eventLayoutNested)
+		return err
+	}
+
+	// The nested fields of a query share the sqlbeat object with the fields describing the event
+	if bt.beatConfig.Sqlbeat.EventLayout == eventLayoutNested {
+		for index, name := range bt.beatConfig.Sqlbeat.QueryNames {
+			for _, metaField := range eventMetaFields {
+				if name == metaField {
+					err := fmt.Errorf("Query #%d name '%v' is a sqlbeat field and can't be used with the nested EventLayout", index, name)
+					return err
+				}
+			}
+		}
+	}
+
+	if bt.beatConfig.Sqlbeat.EncryptionMode == "" {
+		logp.Info("EncryptionMode not selected, proceeding with '%v' as default", defaultEncryptionMode)
+		bt.beatConfig.Sqlbeat.EncryptionMode = defaultEncryptionMode
+	}
+
+	switch bt.beatConfig.Sqlbeat.EncryptionMode {
+	case encryptionModeCFB, encryptionModeGCM:
+		break
+	default:
+		err := fmt.Errorf("Unknown encryption mode, supported encryption modes: `%v`, `%v`", encryptionModeCFB, encryptionModeGCM)
+		return err
+	}
+
+	if len(bt.beatConfig.Sqlbeat.SlaveStatusColumns) == 0 {
+		logp.Info("SlaveStatusColumns not selected, proceeding with '%v' as default", defaultSlaveStatusColumns)
+		bt.beatConfig.Sqlbeat.SlaveStatusColumns = defaultSlaveStatusColumns
+	}
+
+	if bt.beatConfig.Sqlbeat.DeltaOutputMode == "" {
+		logp.Info("DeltaOutputMode not selected, proceeding with '%v' as default", defaultDeltaOutputMode)
+		bt.beatConfig.Sqlbeat.DeltaOutputMode = defaultDeltaOutputMode
+	}
+
+	if bt.beatConfig.Sqlbeat.FloatPrecision == nil {
+		floatPrecision := defaultFloatPrecision
+		bt.beatConfig.Sqlbeat.FloatPrecision = &floatPrecision
+	}
+
+	if bt.beatConfig.Sqlbeat.QueryTimeoutWarnAfter <= 0 {
+		bt.beatConfig.Sqlbeat.QueryTimeoutWarnAfter = defaultQueryTimeoutWarnAfter
+	}
+
+	if bt.beatConfig.Sqlbeat.QueryTimeoutErrorAfter <= 0 {
+		bt.beatConfig.Sqlbeat.QueryTimeoutErrorAfter = defaultQueryTimeoutErrorAfter
+	}
+
+	if bt.beatConfig.Sqlbeat.MaxConsecutiveRowErrors <= 0 {
+		bt.beatConfig.Sqlbeat.MaxConsecutiveRowErrors = defaultMaxRowErrors
+	}
+
+	if bt.beatConfig.Sqlbeat.QueryRetryBackoff == "" {
+		bt.beatConfig.Sqlbeat.QueryRetryBackoff = defaultQueryRetryBackoff
+	}
+
+	if bt.beatConfig.Sqlbeat.ConnectRetryBackoff == "" {
+		bt.beatConfig.Sqlbeat.ConnectRetryBackoff = defaultConnectRetryBackoff
+	}
+
+	// Parse the Period string
+	var durationParseError error
+	bt.period, durationParseError = time.ParseDuration(bt.beatConfig.Sqlbeat.Period)
+	if durationParseError != nil {
+		return durationParseError
+	}
+
+	// Parse the HealthStaleAfter string, when not set the beat is unhealthy after missing a few cycles
+	bt.healthStaleAfter = defaultHealthStaleCycles * bt.period
+	if bt.beatConfig.Sqlbeat.HealthStaleAfter != "" {
+		bt.healthStaleAfter, durationParseError = time.ParseDuration(bt.beatConfig.Sqlbeat.HealthStaleAfter)
+		if durationParseError != nil {
+			return durationParseError
+		}
+	}
+	bt.health.setStaleAfter(bt.healthStaleAfter)
+
+	// Parse the QueryTimeouts strings, an empty timeout means the query has no timeout
+	bt.queryTimeouts = make([]time.Duration, len(bt.beatConfig.Sqlbeat.QueryTimeouts))
+	for index, timeout := range bt.beatConfig.Sqlbeat.QueryTimeouts {
+		if timeout == "" {
+			continue
+		}
+		bt.queryTimeouts[index], durationParseError = time.ParseDuration(timeout)
+		if durationParseError != nil {
+			return durationParseError
+		}
+	}
+
+	// Parse the QueryRetryBackoff string
+	bt.queryRetryBackoff, durationParseError = time.ParseDuration(bt.beatConfig.Sqlbeat.QueryRetryBackoff)
+	if durationParseError != nil {
+		return durationParseError
+	}
+
+	// Parse the ConnectRetryBackoff string
+	bt.connectRetryBackoff, durationParseError = time.ParseDuration(bt.beatConfig.Sqlbeat.ConnectRetryBackoff)
+	if durationParseError != nil {
+		return durationParseError
+	}
+
+	// Parse the MySQL driver timeout strings, an empty timeout leaves the driver default
+	for _, timeout := range []struct {
+		value    string
+		duration *time.Duration
+	}{
+		{bt.beatConfig.Sqlbeat.MySQLTimeout, &bt.mysqlTimeout},
+		{bt.beatConfig.Sqlbeat.MySQLReadTimeout, &bt.mysqlReadTimeout},
+		{bt.beatConfig.Sqlbeat.MySQLWriteTimeout, &bt.mysqlWriteTimeout},
+	} {
+		*timeout.duration = 0
+		if timeout.value == "" {
+			continue
+		}
+		*timeout.duration, durationParseError = time.ParseDuration(timeout.value)
+		if durationParseError != nil {
+			return durationParseError
+		}
+	}
+	bt.mysqlInterpolateParams = bt.beatConfig.Sqlbeat.MySQLInterpolateParams
+
+	bt.deltaWarmupCycles = bt.beatConfig.Sqlbeat.DeltaWarmupCycles
+
+	// Parse the TimestampTruncate string, when not set the timestamps are kept as is
+	if bt.beatConfig.Sqlbeat.TimestampTruncate != "" {
+		bt.timestampTruncate, durationParseError = time.ParseDuration(bt.beatConfig.Sqlbeat.TimestampTruncate)
+		if durationParseError != nil {
+			return durationParseError
+		}
+	}
+
+	// Parse the DeltaMaxAge string, when not set the old values never expire
+	if bt.beatConfig.Sqlbeat.DeltaMaxAge != "" {
+		bt.deltaMaxAge, durationParseError = time.ParseDuration(bt.beatConfig.Sqlbeat.DeltaMaxAge)
+		if durationParseError != nil {
+			return durationParseError
+		}
+	}
+
+	// Parse the DeltaMinInterval string, when not set only samples taken within the same millisecond are skipped
+	if bt.beatConfig.Sqlbeat.DeltaMinInterval != "" {
+		bt.deltaMinInterval, durationParseError = time.ParseDuration(bt.beatConfig.Sqlbeat.DeltaMinInterval)
+		if durationParseError != nil {
+			return durationParseError
+		}
+	}
+
+	// Connect to the DB through an SSH server
+	if bt.beatConfig.Sqlbeat.SSHHost != "" {
+		if bt.beatConfig.Sqlbeat.ConnString != "" || bt.beatConfig.Sqlbeat.DBType == dbtBigQuery {
+			err := fmt.Errorf("SSHHost can't be used with ConnString or with BigQuery")
+			return err
+		}
+		if bt.beatConfig.Sqlbeat.SSHUser == "" || bt.beatConfig.Sqlbeat.SSHKeyFile == "" {
+			err := fmt.Errorf("SSHUser and SSHKeyFile must be selected when using SSHHost")
+			return err
+		}
+		if _, _, err := net.SplitHostPort(bt.beatConfig.Sqlbeat.SSHHost); err != nil {
+			bt.beatConfig.Sqlbeat.SSHHost = net.JoinHostPort(bt.beatConfig.Sqlbeat.SSHHost, defaultSSHPort)
+		}
+		if bt.beatConfig.Sqlbeat.SSHKnownHostsFile == "" {
+			logp.Info("SSHKnownHostsFile not selected, proceeding with '%v' as default", defaultSSHKnownHosts)
+			bt.beatConfig.Sqlbeat.SSHKnownHostsFile = defaultSSHKnownHosts
+		}
+
+		sshClientConfig, err := newSSHClientConfig(bt.beatConfig.Sqlbeat.SSHUser, bt.beatConfig.Sqlbeat.SSHKeyFile,
+			bt.beatConfig.Sqlbeat.SSHKnownHostsFile)
+		if err != nil {
+			return err
+		}
+		bt.sshClientConfig = sshClientConfig
+	}
+	bt.sshHost = bt.beatConfig.Sqlbeat.SSHHost
+
+	bt.authMode = bt.beatConfig.Sqlbeat.AuthMode
+	bt.awsRegion = bt.beatConfig.Sqlbeat.AWSRegion
+
+	// Handle password decryption and save in the bt, token based modes have no password
+	if bt.authMode != authModeSQL {
+		bt.password = ""
+	} else if bt.beatConfig.Sqlbeat.Password != "" {
+		bt.password = bt.beatConfig.Sqlbeat.Password
+	} else if bt.beatConfig.Sqlbeat.EncryptedPassword != "" {
+		password, err := decryptPassword(bt.beatConfig.Sqlbeat.EncryptedPassword, bt.beatConfig.Sqlbeat.EncryptionMode)
+		if err != nil {
+			return err
+		}
+		bt.password = password
+	}
+
+	// init the delta columns state
+	bt.deltaState = newDeltaState()
+	bt.snapshotState = newSnapshotState()
+	bt.eventHashes = newEventHashState()
+	if bt.beatConfig.Sqlbeat.ConnString != "" {
+		// The connection string may hold the password, the target only keeps its hash
+		connStringHash := sha256.Sum256([]byte(bt.beatConfig.Sqlbeat.ConnString))
+		bt.target = fmt.Sprintf("%v://%v", bt.beatConfig.Sqlbeat.DBType, hex.EncodeToString(connStringHash[:8]))
+	} else if bt.beatConfig.Sqlbeat.DBType == dbtBigQuery {
+		bt.target = fmt.Sprintf("%v://%v/%v", dbtBigQuery, bt.beatConfig.Sqlbeat.BigQueryProject,
+			bt.beatConfig.Sqlbeat.BigQueryDataset)
+	} else {
+		bt.target = fmt.Sprintf("%v://%v:%v/%v", bt.beatConfig.Sqlbeat.DBType, bt.beatConfig.Sqlbeat.Hostname,
+			bt.beatConfig.Sqlbeat.Port, bt.beatConfig.Sqlbeat.Database)
+	}
+
+	// Save config values to the bt
+	bt.dbType = bt.beatConfig.Sqlbeat.DBType
+	bt.connString = bt.beatConfig.Sqlbeat.ConnString
+	bt.applicationName = bt.beatConfig.Sqlbeat.ApplicationName
+	bt.hostname = bt.beatConfig.Sqlbeat.Hostname
+	bt.hostnames = bt.beatConfig.Sqlbeat.Hostnames
+	bt.port = bt.beatConfig.Sqlbeat.Port
+	bt.username = bt.beatConfig.Sqlbeat.Username
+	bt.database = bt.beatConfig.Sqlbeat.Database
+	bt.postgresSSLMode = bt.beatConfig.Sqlbeat.PostgresSSLMode
+	bt.postgresSSLCert = bt.beatConfig.Sqlbeat.PostgresSSLCert
+	bt.postgresSSLKey = bt.beatConfig.Sqlbeat.PostgresSSLKey
+	bt.postgresSSLRootCert = bt.beatConfig.Sqlbeat.PostgresSSLRootCert
+	bt.dsnParams = bt.beatConfig.Sqlbeat.DSNParams
+	bt.bigQueryProject = bt.beatConfig.Sqlbeat.BigQueryProject
+	bt.bigQueryDataset = bt.beatConfig.Sqlbeat.BigQueryDataset
+	bt.bigQueryLocation = bt.beatConfig.Sqlbeat.BigQueryLocation
+	bt.bigQueryCredentialsFile = bt.beatConfig.Sqlbeat.BigQueryCredentialsFile
+	bt.trinoCatalog = bt.beatConfig.Sqlbeat.TrinoCatalog
+	bt.trinoHTTPS = bt.beatConfig.Sqlbeat.TrinoHTTPS
+	bt.queries = bt.beatConfig.Sqlbeat.Queries
+	bt.queryTypes = bt.beatConfig.Sqlbeat.QueryTypes
+	bt.queryNames = bt.beatConfig.Sqlbeat.QueryNames
+	bt.queryEventTypes = bt.beatConfig.Sqlbeat.QueryEventTypes
+	bt.eventTypeOverride = bt.beatConfig.Sqlbeat.EventType
+	bt.queryTypeEventTypes = bt.beatConfig.Sqlbeat.QueryTypeEventTypes
+	bt.includeQueryName = bt.beatConfig.Sqlbeat.IncludeQueryName
+	bt.includeQueryText = bt.beatConfig.Sqlbeat.IncludeQueryText
+	bt.includeColumnOrder = bt.beatConfig.Sqlbeat.IncludeColumnOrder
+	bt.queryTexts = make([]string, len(bt.queries))
+	for index, queryStr := range bt.queries {
+		bt.queryTexts[index] = queryText(queryStr, bt.beatConfig.Sqlbeat.QueryTextMaxLength, bt.beatConfig.Sqlbeat.RedactQueryText)
+	}
+	bt.queryNullDefaults = bt.beatConfig.Sqlbeat.QueryNullDefaults
+	bt.includeColumns = bt.beatConfig.Sqlbeat.IncludeColumns
+	bt.excludeColumns = bt.beatConfig.Sqlbeat.ExcludeColumns
+	bt.queryIncludeColumns = bt.beatConfig.Sqlbeat.QueryIncludeColumns
+	bt.queryExcludeColumns = bt.beatConfig.Sqlbeat.QueryExcludeColumns
+	bt.queryExpectedColumns = bt.beatConfig.Sqlbeat.QueryExpectedColumns
+	bt.slaveDelayNullValue = bt.beatConfig.Sqlbeat.SlaveDelayNullValue
+	bt.slaveStatusColumns = make(map[string]bool)
+	for _, strColName := range bt.beatConfig.Sqlbeat.SlaveStatusColumns {
+		bt.slaveStatusColumns[strColName] = true
+	}
+	bt.columnRenames = bt.beatConfig.Sqlbeat.ColumnRenames
+	bt.fieldNameCase = bt.beatConfig.Sqlbeat.FieldNameCase
+	bt.duplicateColumnPolicy = bt.beatConfig.Sqlbeat.DuplicateColumnPolicy
+	bt.byteLengthColumns = make(map[string]bool)
+	for _, strColName := range bt.beatConfig.Sqlbeat.ByteLengthColumns {
+		bt.byteLengthColumns[strColName] = true
+	}
+	bt.jsonColumns = make(map[string]bool)
+	for _, strColName := range bt.beatConfig.Sqlbeat.JSONColumns {
+		bt.jsonColumns[strColName] = true
+	}
+	bt.binaryColumns = bt.beatConfig.Sqlbeat.BinaryColumns
+	bt.epochColumns = bt.beatConfig.Sqlbeat.EpochColumns
+	bt.numberFormat = bt.beatConfig.Sqlbeat.NumberFormat
+	bt.epochTimestampColumn = bt.beatConfig.Sqlbeat.EpochTimestampColumn
+	bt.statusMappings = bt.beatConfig.Sqlbeat.StatusMappings
+	bt.twoColumnsNameIndex = bt.beatConfig.Sqlbeat.TwoColumnsNameIndex
+	bt.twoColumnsValueIndex = *bt.beatConfig.Sqlbeat.TwoColumnsValueIndex
+	bt.deltaWildcard = bt.beatConfig.Sqlbeat.DeltaWildcard
+	bt.queryDeltaWildcards = bt.beatConfig.Sqlbeat.QueryDeltaWildcards
+	bt.emitRawWithDelta = bt.beatConfig.Sqlbeat.EmitRawWithDelta
+	bt.deltaFieldSuffix = bt.beatConfig.Sqlbeat.DeltaFieldSuffix
+	bt.includeDeltaUnit = bt.beatConfig.Sqlbeat.IncludeDeltaUnit
+	bt.deltaOutputMode = bt.beatConfig.Sqlbeat.DeltaOutputMode
+	bt.deltaAllowNegative = bt.beatConfig.Sqlbeat.DeltaAllowNegative
+	bt.deltaNegativeColumns = make(map[string]bool)
+	for _, strColName := range bt.beatConfig.Sqlbeat.DeltaNegativeColumns {
+		bt.deltaNegativeColumns[strColName] = true
+	}
+	bt.includeDeltaInterval = bt.beatConfig.Sqlbeat.IncludeDeltaInterval
+	bt.includeDeltaTimestamps = bt.beatConfig.Sqlbeat.IncludeDeltaTimestamps
+	bt.floatPrecision = *bt.beatConfig.Sqlbeat.FloatPrecision
+	bt.deltaSmoothingAlpha = bt.beatConfig.Sqlbeat.DeltaSmoothingAlpha
+	bt.connectRetries = bt.beatConfig.Sqlbeat.ConnectRetries
+	bt.queryRetries = bt.beatConfig.Sqlbeat.QueryRetries
+	bt.concurrency = bt.beatConfig.Sqlbeat.Concurrency
+	bt.resultBufferSize = bt.beatConfig.Sqlbeat.ResultBufferSize
+	bt.batchSize = bt.beatConfig.Sqlbeat.BatchSize
+	bt.maxRowsPerQuery = bt.beatConfig.Sqlbeat.MaxRowsPerQuery
+	bt.maxRowErrors = bt.beatConfig.Sqlbeat.MaxConsecutiveRowErrors
+	bt.maxEventFields = bt.beatConfig.Sqlbeat.MaxEventFields
+	bt.useColumnTypes = bt.beatConfig.Sqlbeat.UseColumnTypes
+	bt.multipleResultSets = bt.beatConfig.Sqlbeat.MultipleResultSets
+	bt.columnTypeOverrides = make(map[string]int)
+	for strColName, columnTypeName := range bt.beatConfig.Sqlbeat.ColumnTypes {
+		bt.columnTypeOverrides[strColName] = columnTypeNames[columnTypeName]
+	}
+	bt.disableTypeInference = bt.beatConfig.Sqlbeat.DisableTypeInference
+	bt.multiRowMode = bt.beatConfig.Sqlbeat.MultiRowMode
+	bt.multiRowArrayKey = bt.beatConfig.Sqlbeat.MultiRowArrayKey
+	bt.eventLayout = bt.beatConfig.Sqlbeat.EventLayout
+	bt.connectionPools = bt.beatConfig.Sqlbeat.ConnectionPools
+	bt.queryPools = bt.beatConfig.Sqlbeat.QueryPools
+	bt.queryDatabases = bt.beatConfig.Sqlbeat.QueryDatabases
+	bt.queryOutputParams = bt.beatConfig.Sqlbeat.QueryOutputParams
+	bt.queryKeyColumns = bt.beatConfig.Sqlbeat.QueryKeyColumns
+	bt.publishOnChangeOnly = bt.beatConfig.Sqlbeat.PublishOnChangeOnly
+	bt.docIDColumn = bt.beatConfig.Sqlbeat.DocIDColumn
+	if bt.docIDColumn != "" {
+		logp.Info("DocIDColumn is set, the document id is sent as @metadata._id which the Elasticsearch output of this libbeat " +
+			"doesn't apply, send the events through Logstash with document_id => \"%%{[@metadata][_id]}\" to overwrite the documents")
+	}
+	bt.initQueries = bt.beatConfig.Sqlbeat.InitQueries
+	bt.shardIndex = bt.beatConfig.Sqlbeat.ShardIndex
+	bt.shardTotal = bt.beatConfig.Sqlbeat.ShardTotal
+	bt.queryTargets = bt.beatConfig.Sqlbeat.QueryTargets
+	bt.targetSelectors = make(map[string]bool)
+	if bt.beatConfig.Sqlbeat.TargetName != "" {
+		bt.targetSelectors[bt.beatConfig.Sqlbeat.TargetName] = true
+	}
+	for _, tag := range bt.beatConfig.Sqlbeat.TargetTags {
+		bt.targetSelectors[tag] = true
+	}
+	bt.publishQueryMetrics = bt.beatConfig.Sqlbeat.PublishQueryMetrics
+	bt.includeQueryDiagnostics = bt.beatConfig.Sqlbeat.IncludeQueryDiagnostics
+	bt.publishTickEvents = bt.beatConfig.Sqlbeat.PublishTickEvents
+	bt.publishEmptyEvents = bt.beatConfig.Sqlbeat.PublishEmptyEvents
+	bt.metricsAddr = bt.beatConfig.Sqlbeat.MetricsAddr
+	bt.healthAddr = bt.beatConfig.Sqlbeat.HealthAddr
+	bt.debugOutputFile = bt.beatConfig.Sqlbeat.DebugOutputFile
+	bt.debugOutputOnly = bt.beatConfig.Sqlbeat.DebugOutputOnly
+	bt.queryTimeoutWarnAfter = bt.beatConfig.Sqlbeat.QueryTimeoutWarnAfter
+	bt.queryTimeoutErrorAfter = bt.beatConfig.Sqlbeat.QueryTimeoutErrorAfter
+	bt.queryTimeoutAlertAfter = bt.beatConfig.Sqlbeat.QueryTimeoutAlertAfter
+	bt.timeoutCounts = newTimeoutCounts()
+
+	logp.Info("Total # of queries to execute: %d", len(bt.queries))
+	for index, queryStr := range bt.queries {
+		logp.Info("Query %v (type: %s): %s", bt.queryName(index), bt.queryTypes[index], queryStr)
+	}
+
+	if bt.shardTotal > 1 {
+		logp.Info("Running shard %d of %d, only queries where index %% %d == %d will run",
+			bt.shardIndex, bt.shardTotal, bt.shardTotal, bt.shardIndex)
+	}
+
+	// Warn about null defaults for columns that don't seem to be returned by their query
+	for index, nullDefaults := range bt.queryNullDefaults {
+		// two-columns queries return the names as values and a `*` query can return any column
+		if bt.queryTypes[index] == queryTypeTwoColumns || strings.Contains(bt.queries[index], "*") {
+			continue
+		}
+		for strColName := range nullDefaults {
+			if !strings.Contains(strings.ToLower(bt.queries[index]), strings.ToLower(strColName)) {
+				logp.Warn("Query %v has a null default for column '%v' which doesn't appear in the query", bt.queryName(index), strColName)
+			}
+		}
+	}
+
+	// Make sure the (decrypted) password works before running
+	if bt.beatConfig.Sqlbeat.CheckCredentials {
+		err := bt.checkCredentials()
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// checkPerQueryLen returns an error when a per-query setting has more entries than queries
+func (bt *Sqlbeat) checkPerQueryLen(name string, n int) error {
+	if n > len(bt.beatConfig.Sqlbeat.Queries) {
+		return fmt.Errorf("Config file error, %v has more entries than queries (each entry should correspond to the query on the same index)", name)
+	}
+	return nil
+}
+
+// Run is a functions that runs the beat
+func (bt *Sqlbeat) Run(b *beat.Beat) error {
+	logp.Info("sqlbeat is running! Hit CTRL-C to stop it.")
+
+	// Serve the beat's own metrics and health check, the servers are up while waiting for the DB too.
+	// They share a server when they have the same address
+	handlers := make(map[string]map[string]http.Handler)
+	if bt.metricsAddr != "" {
+		handlers[bt.metricsAddr] = map[string]http.Handler{"/metrics": bt.metrics}
+	}
+	if bt.healthAddr != "" {
+		if handlers[bt.healthAddr] == nil {
+			handlers[bt.healthAddr] = make(map[string]http.Handler)
+		}
+		handlers[bt.healthAddr]["/healthz"] = bt.health
+	}
+	for addr, addrHandlers := range handlers {
+		server, err := startHTTPServer(addr, addrHandlers)
+		if err != nil {
+			return err
+		}
+		defer server.Close()
+	}
+
+	// Write the events to a file for testing the config, before connecting so a bad path fails right away
+	if bt.debugOutputFile != "" {
+		output, err := newDebugOutput(bt.debugOutputFile)
+		if err != nil {
+			return err
+		}
+		bt.debugOutput = output
+		defer output.close()
+		if bt.debugOutputOnly {
+			logp.Warn("DebugOutputOnly is set, the events are only written to %v", bt.debugOutputFile)
+		}
+	}
+
+	// The SSH tunnel (if any) is shared by all connections and ticks
+	defer bt.closeTunnel()
+
+	// Connect to the DB, waiting for it to become available if needed
+	err := bt.openDB()
+	if err != nil && err != errStopped && len(bt.hostnames) > 1 {
+		err = bt.failover()
+	}
+	if err == errStopped {
+		return nil
+	} else if err != nil {
+		bt.metrics.addError()
+		return err
+	}
+	defer bt.closeDB()
+
+	// SIGHUP reloads the config file between ticks
+	reloads := make(chan os.Signal, 1)
+	signal.Notify(reloads, syscall.SIGHUP)
+	defer signal.Stop(reloads)
+
+	ticker := time.NewTicker(bt.period)
+	for {
+		select {
+		case <-bt.done:
+			return nil
+		case <-reloads:
+			period := bt.period
+			err := bt.reload(b)
+			if err != nil {
+				logp.Err("Error reloading the config file, keeping the running config: %v", err)
+				bt.metrics.addError()
+			} else if bt.period != period {
+				ticker.Stop()
+				ticker = time.NewTicker(bt.period)
+			}
+			continue
+		case <-ticker.C:
+		}
+
+		err = bt.beat(b)
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// Cleanup is a function that does nothing on this beat :)
+func (bt *Sqlbeat) Cleanup(b *beat.Beat) error {
+	return nil
+}
+
+// Stop is a function that runs once the beat is stopped
+func (bt *Sqlbeat) Stop() {
+	// Cancel the running queries first, so the beat doesn't wait for them to complete
+	bt.cancel()
+	close(bt.done)
+}
+
+///*** sqlbeat methods ***///
+
+// connectionString builds the connection string for the configured DB type
+func (bt *Sqlbeat) connectionString() string {
+
+	// The configured connection string is passed to the driver as is
+	if bt.connString != "" {
+		return bt.connString
+	}
+
+	return bt.databaseConnectionString(bt.database)
+}
+
+// databaseConnectionString builds the connection string of a database of the server for the configured DB type
+func (bt *Sqlbeat) databaseConnectionString(database string) string {
+	connString := ""
+	hostname, port := bt.dialAddress()
+
+	switch bt.dbType {
+	case dbtMSSQL:
+		// Azure AD authentication, the driver gets the token. The user id is the client ID of a user-assigned
+		// managed identity, the system-assigned identity is used without one
+		if bt.authMode == authModeAzureAD || bt.authMode == authModeMSI {
+			fedAuth := azuread.ActiveDirectoryDefault
+			if bt.authMode == authModeMSI {
+				fedAuth = azuread.ActiveDirectoryMSI
+			}
+			connString = fmt.Sprintf("server=%v;port=%v;database=%v;app name=%v;fedauth=%v",
+				hostname, port, database, bt.applicationName, fedAuth)
+			if bt.username != "" {
+				connString += ";user id=" + bt.username
+			}
+			break
+		}
+
+		connString = fmt.Sprintf("server=%v;user id=%v;password=%v;port=%v;database=%v;app name=%v",
+			hostname, bt.username, bt.password, port, database, bt.applicationName)
+
+	case dbtMySQL:
+		// The mysql driver can't set connection attributes, MySQL connections have no application name
+		connString = fmt.Sprintf("%v:%v@tcp(%v:%v)/%v",
+			bt.username, bt.password, hostname, port, database)
+
+		var params []string
+
+		// Multiple result sets are only returned by multi-statement queries and stored procedures
+		if bt.multipleResultSets {
+			params = append(params, "multiStatements=true")
+		}
+
+		// RDS only accepts the auth token as a cleartext password over TLS
+		if bt.authMode == authModeAWSIAM {
+			params = append(params, "tls=true", "allowCleartextPasswords=true")
+		}
+
+		// Driver-level timeouts, so a hung socket can't block a connection or a query forever
+		if bt.mysqlTimeout > 0 {
+			params = append(params, "timeout="+bt.mysqlTimeout.String())
+		}
+		if bt.mysqlReadTimeout > 0 {
+			params = append(params, "readTimeout="+bt.mysqlReadTimeout.String())
+		}
+		if bt.mysqlWriteTimeout > 0 {
+			params = append(params, "writeTimeout="+bt.mysqlWriteTimeout.String())
+		}
+		if bt.mysqlInterpolateParams {
+			params = append(params, "interpolateParams=true")
+		}
+
+		// The driver options of DSNParams (e.g. parseTime, loc, charset or timeout), sorted for a stable connection
+		// string so it isn't seen as changed
+		names := make([]string, 0, len(bt.dsnParams))
+		for name := range bt.dsnParams {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			params = append(params, name+"="+url.QueryEscape(bt.dsnParams[name]))
+		}
+
+		if len(params) > 0 {
+			connString += "?" + strings.Join(params, "&")
+		}
+
+	case dbtPSQL, dbtCockroach:
+		// The credentials are escaped, RDS auth tokens and some passwords have URL characters
+		connString = fmt.Sprintf("%v://%v@%v:%v/%v?sslmode=%v&application_name=%v",
+			dbtPSQL, url.UserPassword(bt.username, bt.password), hostname, port, database, bt.postgresSSLMode,
+			url.QueryEscape(bt.applicationName))
+
+		// Client certificate authentication
+		if bt.postgresSSLCert != "" {
+			connString += "&sslcert=" + url.QueryEscape(bt.postgresSSLCert)
+		}
+		if bt.postgresSSLKey != "" {
+			connString += "&sslkey=" + url.QueryEscape(bt.postgresSSLKey)
+		}
+		if bt.postgresSSLRootCert != "" {
+			connString += "&sslrootcert=" + url.QueryEscape(bt.postgresSSLRootCert)
+		}
+
+	case dbtClickHouse:
+		// The native protocol (tcp), the HTTP interface isn't supported by the driver
+		connString = fmt.Sprintf("tcp://%v:%v?username=%v&password=%v&database=%v",
+			hostname, port, url.QueryEscape(bt.username), url.QueryEscape(bt.password), url.QueryEscape(database))
+
+	case dbtTrino:
+		// The database is the Trino schema, Setup makes sure there is no password over plain HTTP
+		scheme := "http"
+		if bt.trinoHTTPS {
+			scheme = "https"
+		}
+		user := url.User(bt.username)
+		if bt.password != "" {
+			user = url.UserPassword(bt.username, bt.password)
+		}
+		connString = fmt.Sprintf("%v://%v@%v:%v?catalog=%v&source=%v",
+			scheme, user, hostname, port, url.QueryEscape(bt.trinoCatalog), url.QueryEscape(bt.applicationName))
+		if database != "" {
+			connString += "&schema=" + url.QueryEscape(database)
+		}
+
+	case dbtBigQuery:
+		dataset := bt.bigQueryDataset
+		if bt.bigQueryLocation != "" {
+			dataset = bt.bigQueryLocation + "/" + dataset
+		}
+		connString = fmt.Sprintf("%v://%v/%v", dbtBigQuery, bt.bigQueryProject, dataset)
+
+		// Without a credentials file the driver uses GOOGLE_APPLICATION_CREDENTIALS
+		if bt.bigQueryCredentialsFile != "" {
+			connString += "?credURL=" + url.QueryEscape(bt.bigQueryCredentialsFile)
+		}
+	}
+
+	return connString
+}
+
+// driverName returns the name of the go sql driver for the configured DB type
+func (bt *Sqlbeat) driverName() string {
+	if bt.dbType == dbtCockroach {
+		return dbtPSQL
+	}
+	// The Azure AD driver wraps the mssql driver with token authentication
+	if bt.dbType == dbtMSSQL && (bt.authMode == authModeAzureAD || bt.authMode == authModeMSI) {
+		return azuread.DriverName
+	}
+	return bt.dbType
+}
+
+// logServerVersion logs the version reported by the DB server, CockroachDB is detected by its version string
+func (bt *Sqlbeat) logServerVersion(db *sql.DB) {
+	if bt.dbType != dbtCockroach {
+		return
+	}
+
+	var version string
+	err := db.QueryRowContext(bt.ctx, "SELECT version()").Scan(&version)
+	if err != nil {
+		logp.Warn("Error getting the server version: %v %s", err, bt.connectionLogFields(err))
+		return
+	}
+
+	if !strings.Contains(version, "CockroachDB") {
+		logp.Warn("DB type is cockroachdb but the server doesn't report a CockroachDB version: %v", version)
+		return
+	}
+	logp.Info("Connected to %v", version)
+}
+
+// endpoint describes the DB location in the logs
+func (bt *Sqlbeat) endpoint() string {
+	if bt.connString != "" {
+		return "the configured ConnString"
+	}
+	if bt.dbType == dbtBigQuery {
+		return bt.bigQueryProject + "/" + bt.bigQueryDataset
+	}
+	return bt.hostname + ":" + bt.port
+}
+
+// prepareConnection gets what's needed before connecting: a fresh RDS auth token and the SSH tunnel,
+// which is opened once and forwards to the active host
+func (bt *Sqlbeat) prepareConnection() error {
+	err := bt.refreshAuthToken()
+	if err != nil {
+		return err
+	}
+
+	if bt.sshHost == "" {
+		return nil
+	}
+	if bt.tunnel == nil {
+		bt.tunnel, err = newSSHTunnel(bt.sshHost, bt.sshClientConfig)
+		if err != nil {
+			return err
+		}
+	}
+	bt.tunnel.setRemote(net.JoinHostPort(bt.hostname, bt.port))
+	return nil
+}
+
+// dialAddress returns the host and port the connection string connects to, the local end of the SSH tunnel if any
+func (bt *Sqlbeat) dialAddress() (string, string) {
+	if bt.tunnel != nil {
+		return bt.tunnel.localAddr()
+	}
+	return bt.hostname, bt.port
+}
+
+// closeTunnel closes the SSH tunnel, if any
+func (bt *Sqlbeat) closeTunnel() {
+	if bt.tunnel != nil {
+		bt.tunnel.close()
+		bt.tunnel = nil
+	}
+}
+
+// openDB connects to the DB with the current connection parameters, replacing the previous connection if any
+func (bt *Sqlbeat) openDB() error {
+	err := bt.prepareConnection()
+	if err != nil {
+		return err
+	}
+	connString := bt.connectionString()
+
+	db, err := bt.connect(connString)
+	if err != nil {
+		return err
+	}
+	bt.logServerVersion(db)
+
+	// Every named pool gets its own connections, so its queries can't starve the other queries
+	pools := make(map[string]*sql.DB)
+	for name, maxOpenConns := range bt.connectionPools {
+		pool, err := bt.connect(connString)
+		if err != nil {
+			db.Close()
+			for _, pool := range pools {
+				pool.Close()
+			}
+			return err
+		}
+		pool.SetMaxOpenConns(maxOpenConns)
+		pools[name] = pool
+	}
+
+	// The queries of the other databases of the server get a connection per database
+	databases := make(map[string]*sql.DB)
+	for _, database := range bt.queryDatabases {
+		if _, ok := databases[database]; ok || database == "" || database == bt.database {
+			continue
+		}
+		databaseDB, err := bt.connect(bt.databaseConnectionString(database))
+		if err != nil {
+			db.Close()
+			for _, pool := range pools {
+				pool.Close()
+			}
+			for _, databaseDB := range databases {
+				databaseDB.Close()
+			}
+			return err
+		}
+		databases[database] = databaseDB
+	}
+
+	// Close the connections opened with the previous parameters
+	bt.closeDB()
+
+	bt.db = db
+	bt.pools = pools
+	bt.databases = databases
+	bt.dbConnString = connString
+	return nil
+}
+
+// failover connects to the first of the Hostnames that accepts connections, starting with the host after the
+// active host. The host that connected becomes the active host, so the hosts are only switched when it fails
+func (bt *Sqlbeat) failover() error {
+	active := bt.hostname
+	start := 0
+	for index, hostname := range bt.hostnames {
+		if hostname == active {
+			start = index
+		}
+	}
+
+	var err error
+	for i := 1; i <= len(bt.hostnames); i++ {
+		bt.hostname = bt.hostnames[(start+i)%len(bt.hostnames)]
+		err = bt.openDB()
+		if err == nil {
+			if bt.hostname != active {
+				logp.Warn("Failed over from %v to %v", active, bt.hostname)
+			}
+			// The counters of the new host have nothing to do with the ones of the previous host
+			bt.target = fmt.Sprintf("%v://%v:%v/%v", bt.dbType, bt.hostname, bt.port, bt.database)
+			return nil
+		}
+		if err == errStopped {
+			break
+		}
+		logp.Warn("Could not fail over to %v: %v %s", bt.hostname, err, bt.connectionLogFields(err))
+	}
+
+	bt.hostname = active
+	return err
+}
+
+// closeDB closes the DB connection, the named pools and the connections of the other databases
+func (bt *Sqlbeat) closeDB() {
+	if bt.db != nil {
+		bt.db.Close()
+	}
+	for _, pool := range bt.pools {
+		pool.Close()
+	}
+	for _, databaseDB := range bt.databases {
+		databaseDB.Close()
+	}
+}
+
+// queryDB returns the connection pool or the database a query is assigned to, other queries use the default connection
+func (bt *Sqlbeat) queryDB(index int) *sql.DB {
+	if index < len(bt.queryPools) && bt.queryPools[index] != "" {
+		return bt.pools[bt.queryPools[index]]
+	}
+	if index < len(bt.queryDatabases) && bt.queryDatabases[index] != "" && bt.queryDatabases[index] != bt.database {
+		return bt.databases[bt.queryDatabases[index]]
+	}
+	return bt.db
+}
+
+// connect opens the DB and pings it (which runs the InitQueries on the first connection), retrying with an exponential backoff until ConnectRetries is exhausted
+func (bt *Sqlbeat) connect(connString string) (*sql.DB, error) {
+	backoff := bt.connectRetryBackoff
+
+	for attempt := 1; ; attempt++ {
+		db, err := bt.openSQL(connString)
+		if err == nil {
+			// sql.Open doesn't connect, ping to make sure the DB is reachable
+			err = db.PingContext(bt.ctx)
+			if err == nil {
+				return db, nil
+			}
+			db.Close()
+		}
+
+		if attempt > bt.connectRetries {
+			return nil, fmt.Errorf("Could not connect to %v at %v after %d attempt(s): %v",
+				bt.dbType, bt.endpoint(), attempt, err)
+		}
+
+		logp.Warn("Connection attempt #%d to %v at %v failed: %v, retrying in %v %s",
+			attempt, bt.dbType, bt.endpoint(), err, backoff, bt.connectionLogFields(err))
+
+		select {
+		case <-bt.done:
+			return nil, errStopped
+		case <-time.After(backoff):
+		}
+
+		// Double the backoff for the next attempt
+		backoff *= 2
+		if backoff > maxConnectRetryBackoff {
+			backoff = maxConnectRetryBackoff
+		}
+	}
+}
+
+// checkCredentials connects to the DB once, a wrong secret decrypts EncryptedPassword into garbage
+// which otherwise only shows up as an authentication error on the first period
+func (bt *Sqlbeat) checkCredentials() error {
+	err := bt.prepareConnection()
+	if err != nil {
+		return err
+	}
+
+	db, err := bt.openSQL(bt.connectionString())
+	if err == nil {
+		defer db.Close()
+
+		ctx, cancel := context.WithTimeout(bt.ctx, checkCredentialsTimeout)
+		defer cancel()
+		err = db.PingContext(ctx)
+	}
+	if err == nil {
+		return nil
+	}
+
+	if bt.beatConfig.Sqlbeat.EncryptedPassword != "" && bt.beatConfig.Sqlbeat.Password == "" {
+		return fmt.Errorf("Could not connect to %v at %v, decryption of EncryptedPassword likely failed "+
+			"(compiled with a different secret) or the credentials are wrong: %v", bt.dbType, bt.endpoint(), err)
+	}
+	return fmt.Errorf("Could not connect to %v at %v, the credentials are likely wrong: %v",
+		bt.dbType, bt.endpoint(), err)
+}
+
+// ValidateQueries prepares every query against the DB, which parses the query without running it,
+// and reports the queries with syntax errors. It's called instead of Run, once the beat is set up
+func (bt *Sqlbeat) ValidateQueries() error {
+	err := bt.prepareConnection()
+	if err != nil {
+		return err
+	}
+
+	db, err := bt.connect(bt.connectionString())
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	ctx := context.Background()
+	invalid := 0
+
+	for index, queryStr := range bt.queries {
+		// The queries of other targets may not be valid on this one
+		if !bt.inTarget(index) {
+			fmt.Printf("Query %v is skipped, it doesn't run on this target\n", bt.queryName(index))
+			continue
+		}
+
+		err := bt.prepareQuery(ctx, db, bt.queryStatement(index, queryStr))
+		if err != nil {
+			invalid++
+			logp.Err("Query %v is invalid: %v %s", bt.queryName(index), err, bt.queryLogFields(index, err))
+			fmt.Printf("Query %v is invalid: %v\n", bt.queryName(index), err)
+			continue
+		}
+		fmt.Printf("Query %v is valid\n", bt.queryName(index))
+	}
+
+	if invalid > 0 {
+		return fmt.Errorf("%d of %d queries are invalid", invalid, len(bt.queries))
+	}
+
+	return nil
+}
+
+// prepareQuery parses a query on the DB server without running it
+func (bt *Sqlbeat) prepareQuery(ctx context.Context, db *sql.DB, queryStr string) error {
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	// The mssql driver doesn't prepare statements on the server, use PARSEONLY instead
+	if bt.dbType == dbtMSSQL {
+		_, err = conn.ExecContext(ctx, "SET PARSEONLY ON")
+		if err != nil {
+			return err
+		}
+		defer conn.ExecContext(ctx, "SET PARSEONLY OFF")
+
+		_, err = conn.ExecContext(ctx, queryStr)
+		return err
+	}
+
+	stmt, err := conn.PrepareContext(ctx, queryStr)
+	if err != nil {
+		return err
+	}
+	return stmt.Close()
+}
+
+// beat is a function that iterate over the query array, generate and publish events
+func (bt *Sqlbeat) beat(b *beat.Beat) error {
+
+	// Start counting the tick, its summary is published once all of its queries are done
+	bt.tickCount++
+	bt.tick = newTickStats(bt.tickCount)
+	if bt.deltaWarmupCycles > 0 && bt.tickCount == bt.deltaWarmupCycles+1 {
+		logp.Info("Delta warm-up completed after %d cycles, the delta columns are sent from now on", bt.deltaWarmupCycles)
+	}
+	defer bt.publishTickEvent(b)
+	defer bt.metrics.addTick(bt.tick)
+
+	// Drop the old values of the delta columns that stopped appearing
+	if bt.deltaMaxAge > 0 {
+		if pruned := bt.deltaState.prune(time.Now().Add(-bt.deltaMaxAge)); pruned > 0 {
+			logp.Debug("sqlbeat", "Pruned %d delta columns older than %v", pruned, bt.deltaMaxAge)
+		}
+	}
+
+	// Reconnect when the connection parameters changed since the DB was opened (e.g. after a config reload), a
+	// refreshed RDS auth token isn't one of them as only the new connections need it
+	err := bt.prepareConnection()
+	if err != nil {
+		// A failed token fetch or tunnel dial only matters for new connections, the open ones are still
+		// authenticated and used for this cycle (the ping tells whether they still work)
+		logp.Err("Error preparing the connection to %v at %v, keeping the current connection: %v %s", bt.dbType, bt.endpoint(), err, bt.connectionLogFields(err))
+		bt.tick.addError()
+	} else if bt.connectionString() != bt.dbConnString {
+		logp.Info("Connection parameters changed, reconnecting to %v at %v", bt.dbType, bt.endpoint())
+		err := bt.openDB()
+		if err == errStopped {
+			return nil
+		}
+		// The previous connection (if any) is kept and the reconnect is retried on the next tick
+		if err != nil {
+			logp.Err("Error reconnecting to %v at %v, skipping this cycle: %v %s", bt.dbType, bt.endpoint(), err, bt.connectionLogFields(err))
+			bt.tick.addError()
+			return nil
+		}
+	}
+
+	db := bt.db
+	ctx := bt.ctx
+
+	// The DB may have gone away since the last cycle, fail over to the other hosts if any,
+	// otherwise skip this cycle instead of failing on the first query
+	err = db.PingContext(ctx)
+	if err != nil && len(bt.hostnames) > 1 && ctx.Err() == nil {
+		logp.Warn("Error pinging %v at %v, failing over to the other hosts: %v %s", bt.dbType, bt.endpoint(), err, bt.connectionLogFields(err))
+		err = bt.failover()
+		db = bt.db
+	}
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil
+		}
+		logp.Err("Error pinging %v at %v, skipping this cycle: %v %s", bt.dbType, bt.endpoint(), err, bt.connectionLogFields(err))
+		bt.tick.addError()
+		return nil
+	}
+
+	// Run the queries one after the other
+	if bt.concurrency <= 1 {
+		for index, queryStr := range bt.queries {
+			// The beat was stopped (Stop cancels the context before closing done), skip the remaining queries
+			if ctx.Err() != nil {
+				logp.Debug("sqlbeat", "Beat stopped, skipping the queries from query %v", bt.queryName(index))
+				return nil
+			}
+
+			if !bt.inShard(index) || !bt.inTarget(index) || !bt.hasQueryType(index) {
+				continue
+			}
+
+			bt.runQueryOrSkip(ctx, b, index, queryStr)
+		}
+
+		// The cycle completed with the DB reachable, failed queries are counted in the metrics
+		bt.health.addSuccess(time.Now())
+
+		// Great success!
+		return nil
+	}
+
+	// Run the queries in a pool of up to `concurrency` workers
+	var wg sync.WaitGroup
+	semaphore := make(chan struct{}, bt.concurrency)
+
+LoopQueries:
+	for index, queryStr := range bt.queries {
+		// The beat was stopped (Stop cancels the context before closing done), skip the remaining queries
+		if ctx.Err() != nil {
+			logp.Debug("sqlbeat", "Beat stopped, skipping the queries from query %v", bt.queryName(index))
+			break LoopQueries
+		}
+
+		if !bt.inShard(index) || !bt.inTarget(index) || !bt.hasQueryType(index) {
+			continue
+		}
+
+		// Wait for a free worker, unless the beat is stopped while waiting
+		select {
+		case semaphore <- struct{}{}:
+		case <-ctx.Done():
+			logp.Debug("sqlbeat", "Beat stopped, skipping the queries from query %v", bt.queryName(index))
+			break LoopQueries
+		}
+		wg.Add(1)
+
+		go func(index int, queryStr string) {
+			defer wg.Done()
+			defer func() { <-semaphore }()
+
+			bt.runQueryOrSkip(ctx, b, index, queryStr)
+		}(index, queryStr)
+	}
+
+	wg.Wait()
+
+	// The cycle completed with the DB reachable unless the beat was stopped, failed queries are counted in the metrics
+	if ctx.Err() == nil {
+		bt.health.addSuccess(time.Now())
+	}
+
+	// Great success!
+	return nil
+}
+
+// addQueryError counts an error of a query in the tick and in the per-query error counts of the metrics
+func (bt *Sqlbeat) addQueryError(index int) {
+	bt.tick.addError()
+	bt.metrics.addQueryError(bt.queryName(index))
+}
+
+// runQueryOrSkip runs a query, a failed query is logged and skipped for this cycle so it doesn't stop the
+// other queries (or the beat)
+func (bt *Sqlbeat) runQueryOrSkip(ctx context.Context, b *beat.Beat, index int, queryStr string) {
+	err := bt.runQuery(ctx, b, bt.queryDB(index), index, queryStr)
+	if err != nil {
+		logp.Err("Query %v error, skipping it this cycle: %v %s", bt.queryName(index), err, bt.queryLogFields(index, err))
+		bt.addQueryError(index)
+	}
+}
+
+// queryDeltaWildcard returns the delta wildcard of a query, the query's own delta wildcard takes precedence over
+// DeltaWildcard and a wildcard of "-" disables the delta columns of the query (an empty wildcard is returned)
+func (bt *Sqlbeat) queryDeltaWildcard(index int) string {
+	deltaWildcard := bt.deltaWildcard
+	if index < len(bt.queryDeltaWildcards) && bt.queryDeltaWildcards[index] != "" {
+		deltaWildcard = bt.queryDeltaWildcards[index]
+	}
+	if deltaWildcard == deltaWildcardDisabled {
+		return ""
+	}
+	return deltaWildcard
+}
+
+// isDeltaColumn returns whether a column of a query is a delta column
+func (bt *Sqlbeat) isDeltaColumn(index int, strColName string) bool {
+	deltaWildcard := bt.queryDeltaWildcard(index)
+	return deltaWildcard != "" && strings.HasSuffix(strColName, deltaWildcard)
+}
+
+// setRawDeltaValue adds the raw value of a delta column next to its delta when EmitRawWithDelta is enabled,
+// under the column name without the delta wildcard
+func (bt *Sqlbeat) setRawDeltaValue(event common.MapStr, index int, strColName string, strColValue string, columnType int, rowAge time.Time) {
+	if !bt.emitRawWithDelta {
+		return
+	}
+	rawColName := strings.TrimSuffix(strColName, bt.queryDeltaWildcard(index))
+	bt.setTypedColumnValue(event, rawColName, strColValue, columnType, false, rowAge)
+}
+
+// labelDeltaValue names the delta of a delta column after its unit when DeltaFieldSuffix is set (the column name
+// without the delta wildcard plus the suffix, e.g. Com_select_per_sec) and adds a <field>_unit field with the unit
+// of the delta when IncludeDeltaUnit is enabled. Columns with a ColumnRenames entry keep their name
+func (bt *Sqlbeat) labelDeltaValue(event common.MapStr, index int, strColName string) {
+	// Cumulative mode reports the raw counter, which has no unit
+	if bt.deltaOutputMode == deltaOutputModeCumulative {
+		return
+	}
+
+	fieldName := bt.fieldName(strColName)
+	if _, ok := event[fieldName]; !ok {
+		// No delta yet, or a skipped sample
+		return
+	}
+
+	if deltaFieldName := bt.deltaFieldName(index, strColName); deltaFieldName != fieldName {
+		// Move the delta and the fields added next to it
+		for _, suffix := range []string{"", "_smoothed", "_interval_seconds", "_delta_start", "_delta_end"} {
+			if value, ok := event[fieldName+suffix]; ok {
+				delete(event, fieldName+suffix)
+				event[deltaFieldName+suffix] = value
+			}
+		}
+		fieldName = deltaFieldName
+	}
+
+	if bt.includeDeltaUnit {
+		if bt.deltaOutputMode == deltaOutputModeIncrement {
+			event[fieldName+"_unit"] = deltaUnitPerInterval
+		} else {
+			event[fieldName+"_unit"] = deltaUnitPerSecond
+		}
+	}
+}
+
+// deltaFieldName returns the field name of the delta of a delta column, see labelDeltaValue
+func (bt *Sqlbeat) deltaFieldName(index int, strColName string) string {
+	if _, renamed := bt.columnRenames[strColName]; renamed || bt.deltaFieldSuffix == "" {
+		return bt.fieldName(strColName)
+	}
+	return bt.fieldName(strings.TrimSuffix(strColName, bt.queryDeltaWildcard(index))) + bt.deltaFieldSuffix
+}
+
+// collectsRows returns whether the rows of a query are collected into a single event, like the rows of the
+// multiple-rows queries in array mode
+func (bt *Sqlbeat) collectsRows(index int) bool {
+	return bt.queryTypes[index] == queryTypeMultipleRows && bt.multiRowMode == multiRowModeArray
+}
+
+// columnOrder returns the fields of the columns of a result set in the order of the columns, for consumers that
+// rebuild a table from the events. Only the fields found in one of the events are returned (filtered out or
+// NULL columns have none)
+func (bt *Sqlbeat) columnOrder(index int, columns []string, events ...common.MapStr) []string {
+	fieldNames := make([]string, 0, len(columns))
+	seen := make(map[string]bool, len(columns))
+	for _, strColName := range columns {
+		// A column's field may be named after its content or its delta
+		candidates := []string{bt.fieldName(strColName)}
+		if bt.byteLengthColumns[strColName] {
+			candidates = append(candidates, bt.fieldName(strColName)+"_bytes")
+		}
+		if bt.isDeltaColumn(index, strColName) {
+			candidates = append(candidates, bt.deltaFieldName(index, strColName))
+		}
+
+	LoopCandidates:
+		for _, fieldName := range candidates {
+			for _, event := range events {
+				if _, ok := event[fieldName]; ok && !seen[fieldName] {
+					seen[fieldName] = true
+					fieldNames = append(fieldNames, fieldName)
+					break LoopCandidates
+				}
+			}
+		}
+	}
+	return fieldNames
+}
+
+// inDeltaWarmup returns whether the current cycle is one of the first DeltaWarmupCycles cycles after startup
+func (bt *Sqlbeat) inDeltaWarmup() bool {
+	return bt.deltaWarmupCycles > 0 && bt.tickCount <= bt.deltaWarmupCycles
+}
+
+// eventTime returns the @timestamp of a query event, truncated to a multiple of TimestampTruncate (when set) so
+// the events of the queries of a cycle fall in the same time bucket
+func (bt *Sqlbeat) eventTime(timestamp time.Time) common.Time {
+	if bt.timestampTruncate > 0 {
+		timestamp = timestamp.Truncate(bt.timestampTruncate)
+	}
+	return common.Time(timestamp)
+}
+
+// inShard returns whether a query belongs to the shard of this instance, all queries do when sharding is disabled
+func (bt *Sqlbeat) inShard(index int) bool {
+	if bt.shardTotal <= 1 {
+		return true
+	}
+	return index%bt.shardTotal == bt.shardIndex
+}
+
+// inTarget returns whether a query runs on the target of this instance, a query with target selectors only runs
+// when one of them is the TargetName or one of the TargetTags, queries without selectors run on all targets
+func (bt *Sqlbeat) inTarget(index int) bool {
+	if index >= len(bt.queryTargets) || len(bt.queryTargets[index]) == 0 {
+		return true
+	}
+	for _, selector := range bt.queryTargets[index] {
+		if bt.targetSelectors[selector] {
+			return true
+		}
+	}
+	return false
+}
+
+// hasQueryType returns whether a query has a query type, Setup makes sure all queries do but the queries
+// and their types may get out of sync (e.g. by a config reload), a query without a type is skipped
+func (bt *Sqlbeat) hasQueryType(index int) bool {
+	if index < len(bt.queryTypes) && bt.queryTypes[index] != "" {
+		return true
+	}
+
+	logp.Err("Query %v has no query type (%d queries, %d query types), skipping it", bt.queryName(index), len(bt.queries), len(bt.queryTypes))
+	bt.addQueryError(index)
+	return false
+}
+
+// runQuery runs a single query, generates and publishes its events
+func (bt *Sqlbeat) runQuery(ctx context.Context, b *beat.Beat, db *sql.DB, index int, queryStr string) error {
+
+	bt.tick.addQuery()
+
+	// Create a two-columns event for later use
+	var twoColumnEvent common.MapStr
+
+	// Apply the query timeout, if any
+	if index < len(bt.queryTimeouts) && bt.queryTimeouts[index] > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, bt.queryTimeouts[index])
+		defer cancel()
+	}
+
+	// Log the query run time and run the query
+	dtNow := time.Now()
+	var rows *sql.Rows
+	q, conn, err := bt.queryConn(ctx, db)
+	if err == nil {
+		if conn != nil {
+			defer conn.Close()
+		}
+		rows, err = bt.queryWithRetries(ctx, q, index, bt.queryStatement(index, queryStr))
+	}
+	if err != nil {
+		// A timed out query is skipped for this cycle
+		if ctx.Err() == context.DeadlineExceeded {
+			bt.queryTimedOut(b, index)
+			return nil
+		}
+		// The beat was stopped while the query was running
+		if ctx.Err() == context.Canceled {
+			return nil
+		}
+		if bt.publishQueryMetrics && bt.includeQueryDiagnostics {
+			bt.publishQueryMetricsEvent(b, index, time.Since(dtNow), 0, common.MapStr{"query_error": err.Error()})
+		}
+		return err
+	}
+	defer rows.Close()
+
+	// Populate columns array
+	columns, err := rows.Columns()
+	if err != nil {
+		return err
+	}
+
+	// Catch queries that no longer return the columns they're expected to return
+	bt.checkExpectedColumns(b, index, columns)
+
+	// Columns sharing a name would overwrite each other in the event
+	columns, err = bt.dedupColumns(index, columns)
+	if err != nil {
+		return err
+	}
+
+	// Type the values by the column types the driver declares instead of inferring them from the values
+	var columnTypes []int
+	if bt.useColumnTypes {
+		declaredTypes, err := rows.ColumnTypes()
+		if err != nil {
+			logp.Warn("Query %v error getting the column types, inferring the types from the values: %v %s", bt.queryName(index), err, bt.queryLogFields(index, err))
+		} else {
+			columnTypes = declaredColumnTypes(declaredTypes)
+		}
+	}
+
+	// Publish the events from a separate goroutine through a bounded channel,
+	// scanning the rows pauses whenever publishing falls behind
+	events := make(chan common.MapStr, bt.resultBufferSize)
+	published := make(chan struct{})
+	go func() {
+		defer close(published)
+		bt.publishEvents(b, index, events)
+	}()
+	defer func() {
+		close(events)
+		<-published
+	}()
+
+	// Populate the two-columns event, a query returning too few columns is skipped for this cycle
+	if bt.queryTypes[index] == queryTypeTwoColumns {
+		if bt.twoColumnsNameIndex >= len(columns) || bt.twoColumnsValueIndex >= len(columns) {
+			return fmt.Errorf("Query %v returns %d columns, TwoColumnsNameIndex (%d) and TwoColumnsValueIndex (%d) must be lower than the number of columns",
+				bt.queryName(index), len(columns), bt.twoColumnsNameIndex, bt.twoColumnsValueIndex)
+		}
+
+		twoColumnEvent = common.MapStr{
+			"@timestamp": bt.eventTime(dtNow),
+			"type":       bt.eventType(index),
+		}
+	}
+
+	rowCount := 0
+	droppedFields := 0
+
+	collectRows := bt.collectsRows(index)
+	var collectedRows []common.MapStr
+
+	// multiple-rows queries skip the rows that fail, up to maxRowErrors consecutive failed rows
+	rowErrors := 0
+
+	// The rows of a snapshot-diff query are compared to the rows of the previous cycle once all were read
+	var snapshot map[string]common.MapStr
+	if bt.queryTypes[index] == queryTypeSnapshotDiff {
+		snapshot = make(map[string]common.MapStr)
+	}
+
+LoopRows:
+	for rows.Next() || bt.nextResultSet(rows, index, &columns, &columnTypes) {
+
+		// Stop reading the rows once the beat is stopped or the query timed out
+		if ctx.Err() != nil {
+			break LoopRows
+		}
+
+		// Protect the output from queries returning way more rows than expected
+		if bt.maxRowsPerQuery > 0 && rowCount == bt.maxRowsPerQuery {
+			logp.Warn("Query %v returned more than %d rows, the remaining rows are skipped", bt.queryName(index), bt.maxRowsPerQuery)
+			// A partial snapshot would report the skipped rows as removed
+			snapshot = nil
+			break LoopRows
+		}
+		rowCount++
+
+		switch bt.queryTypes[index] {
+		case queryTypeSingleRow, queryTypeSlaveDelay, queryTypePgReplicationLag:
+			// Generate an event from the current row
+			event, err := bt.generateEventFromRow(rows, columns, columnTypes, index, bt.queryTypes[index], dtNow)
+
+			if err != nil {
+				logp.Err("Query %v error generating event from rows: %v %s", bt.queryName(index), err, bt.queryLogFields(index, err))
+				bt.addQueryError(index)
+			} else if event != nil {
+				events <- event
+			}
+			// breaking after the first row
+			break LoopRows
+
+		case queryTypeMultipleRows, queryTypeAllSlavesStatus, queryTypeTimeSeries, queryTypeStoredProcedure:
+			// Generate an event from the current row
+			event, err := bt.generateEventFromRow(rows, columns, columnTypes, index, bt.queryTypes[index], dtNow)
+
+			if err != nil {
+				logp.Err("Query %v error generating event from rows: %v %s", bt.queryName(index), err, bt.queryLogFields(index, err))
+				bt.addQueryError(index)
+				rowErrors++
+				if rowErrors >= bt.maxRowErrors {
+					logp.Err("Query %v failed on %d consecutive rows, the remaining rows are skipped %s", bt.queryName(index), rowErrors, bt.queryLogFields(index, err))
+					break LoopRows
+				}
+				// Skip the failed row
+				continue LoopRows
+			}
+			rowErrors = 0
+
+			if event != nil && collectRows {
+				// The rows share the @timestamp and type of the array event
+				delete(event, "@timestamp")
+				delete(event, "type")
+				collectedRows = append(collectedRows, event)
+			} else if event != nil {
+				events <- event
+			}
+
+			// Move to the next row
+			continue LoopRows
+
+		case queryTypeLongFormat:
+			// Generate an event from the current row
+			event, err := bt.generateEventFromRow(rows, columns, columnTypes, index, bt.queryTypes[index], dtNow)
+
+			if err != nil {
+				logp.Err("Query %v error generating event from rows: %v %s", bt.queryName(index), err, bt.queryLogFields(index, err))
+				bt.addQueryError(index)
+				break LoopRows
+			}
+
+			// Split the event to one event per column
+			for _, metricEvent := range splitEvent(event) {
+				events <- metricEvent
+			}
+
+			// Move to the next row
+			continue LoopRows
+
+		case queryTypeSnapshotDiff:
+			// Generate an event from the current row and add it to the snapshot by its key
+			event, err := bt.generateEventFromRow(rows, columns, columnTypes, index, bt.queryTypes[index], dtNow)
+			if err != nil {
+				logp.Err("Query %v error generating event from rows: %v %s", bt.queryName(index), err, bt.queryLogFields(index, err))
+				bt.addQueryError(index)
+				snapshot = nil
+				break LoopRows
+			}
+
+			keyColumn := bt.queryKeyColumns[index]
+			rowKey, ok := snapshotRowKey(event, bt.fieldName(keyColumn))
+			if !ok {
+				logp.Err("Query %v returned a row without its key column '%v'", bt.queryName(index), keyColumn)
+				bt.addQueryError(index)
+				snapshot = nil
+				break LoopRows
+			}
+			snapshot[rowKey] = event
+
+			// Move to the next row
+			continue LoopRows
+
+		case queryTypeTwoColumns:
+			// append current row to the two-columns event
+			err := bt.appendRowToEvent(twoColumnEvent, rows, columns, columnTypes, index, dtNow)
+
+			// The remaining names are counted but not added once the event is full
+			if err == errMaxEventFields {
+				droppedFields++
+				continue LoopRows
+			}
+
+			if err != nil {
+				logp.Err("Query %v error appending two-columns event: %v %s", bt.queryName(index), err, bt.queryLogFields(index, err))
+				bt.addQueryError(index)
+				break LoopRows
+			}
+
+			// Move to the next row
+			continue LoopRows
+		}
+	}
+
+	if droppedFields > 0 {
+		logp.Warn("Query %v reached MaxEventFields (%d), %d names were dropped", bt.queryName(index), bt.maxEventFields, droppedFields)
+	}
+
+	// Mark the queries that returned no rows with an empty event
+	if rowCount == 0 && bt.publishEmptyEvents && ctx.Err() == nil {
+		events <- common.MapStr{
+			"@timestamp": bt.eventTime(dtNow),
+			"type":       bt.eventType(index),
+			"sqlbeat": common.MapStr{
+				"query":     bt.queryName(index),
+				"row_count": 0,
+			},
+		}
+	}
+
+	// If rows were collected, publish them as an array (unless the rows were only partially read)
+	if len(collectedRows) > 0 && ctx.Err() == nil {
+		event := common.MapStr{
+			"@timestamp":        bt.eventTime(dtNow),
+			"type":              bt.eventType(index),
+			bt.multiRowArrayKey: collectedRows,
+			"sqlbeat": common.MapStr{
+				"row_count": rowCount,
+			},
+		}
+		if bt.includeColumnOrder {
+			setEventMeta(event, "columns", bt.columnOrder(index, columns, collectedRows...))
+		}
+		events <- event
+	}
+
+	// Publish the rows added and removed since the previous cycle (unless the rows were only partially read),
+	// the first cycle only takes the snapshot
+	if snapshot != nil && ctx.Err() == nil && rows.Err() == nil {
+		previous, exists := bt.snapshotState.swap(bt.target+"|"+queryStr, snapshot)
+		if exists {
+			added, removed := diffSnapshots(previous, snapshot)
+			for _, event := range added {
+				event = copyEvent(event)
+				setEventMeta(event, "change", "added")
+				events <- event
+			}
+			for _, event := range removed {
+				event = copyEvent(event)
+				event["@timestamp"] = bt.eventTime(dtNow)
+				setEventMeta(event, "change", "removed")
+				events <- event
+			}
+		}
+	}
+
+	// If the two-columns event has data, publish it (unless the rows were only partially read)
+	if bt.queryTypes[index] == queryTypeTwoColumns && len(twoColumnEvent) > 2 && ctx.Err() == nil {
+		events <- twoColumnEvent
+	}
+
+	rows.Close()
+	if err = rows.Err(); err != nil || ctx.Err() != nil {
+		if ctx.Err() == context.DeadlineExceeded {
+			bt.queryTimedOut(b, index)
+			return nil
+		}
+		if ctx.Err() == context.Canceled {
+			return nil
+		}
+		logp.Err("Query %v error closing rows: %v %s", bt.queryName(index), err, bt.queryLogFields(index, err))
+		bt.addQueryError(index)
+	}
+
+	// The query completed in time, reset its consecutive timeouts
+	bt.timeoutCounts.reset(index)
+
+	if bt.publishQueryMetrics {
+		var diagnostics common.MapStr
+		if bt.includeQueryDiagnostics {
+			diagnostics = bt.queryDiagnostics(ctx, conn, index, err)
+		}
+		bt.publishQueryMetricsEvent(b, index, time.Since(dtNow), rowCount, diagnostics)
+	}
+
 	return nil
 }
 
-// generateEventFromRow creates a new event from the row data and returns it
-func (bt *Sqlbeat) generateEventFromRow(row *sql.Rows, columns []string, queryType string, rowAge time.Time) (common.MapStr, error) {
+// publishQueryMetricsEvent publishes the time it took to run a query and read its rows, and the number of rows
+func (bt *Sqlbeat) publishQueryMetricsEvent(b *beat.Beat, index int, duration time.Duration, rowCount int, diagnostics common.MapStr) {
+	metrics := common.MapStr{
+		"query_index":       index,
+		"query":             bt.queryName(index),
+		"query_type":        bt.queryTypes[index],
+		"query_duration_ms": float64(duration) / float64(time.Millisecond),
+		"row_count":         rowCount,
+	}
+	for key, value := range diagnostics {
+		metrics[key] = value
+	}
+
+	event := common.MapStr{
+		"@timestamp": common.Time(time.Now()),
+		"type":       eventTypeQueryMetrics,
+		"sqlbeat":    metrics,
+	}
+	bt.publish(b, event)
+	logp.Debug("sqlbeat", "Query %v took %v", bt.queryName(index), duration)
+}
+
+// nextResultSet moves to the first row of the next result set that has rows when MultipleResultSets is set
+// (stored procedures always read all of their result sets), the columns and the column types are replaced
+// by the ones of the result set
+func (bt *Sqlbeat) nextResultSet(rows *sql.Rows, index int, columns *[]string, columnTypes *[]int) bool {
+	if !bt.multipleResultSets && bt.queryTypes[index] != queryTypeStoredProcedure {
+		return false
+	}
+
+	for rows.NextResultSet() {
+		resultSetColumns, err := rows.Columns()
+		if err != nil {
+			logp.Err("Query %v error getting the columns of the next result set: %v %s", bt.queryName(index), err, bt.queryLogFields(index, err))
+			bt.addQueryError(index)
+			return false
+		}
+
+		// The rows of all result sets are added to the same two-columns event
+		if bt.queryTypes[index] == queryTypeTwoColumns &&
+			(bt.twoColumnsNameIndex >= len(resultSetColumns) || bt.twoColumnsValueIndex >= len(resultSetColumns)) {
+			logp.Err("Query %v returns a result set with %d columns, the remaining result sets are skipped", bt.queryName(index), len(resultSetColumns))
+			bt.addQueryError(index)
+			return false
+		}
+
+		resultSetColumns, err = bt.dedupColumns(index, resultSetColumns)
+		if err != nil {
+			logp.Err("%v, the remaining result sets are skipped", err)
+			bt.addQueryError(index)
+			return false
+		}
+
+		*columns = resultSetColumns
+		*columnTypes = nil
+		if bt.useColumnTypes {
+			if declaredTypes, err := rows.ColumnTypes(); err == nil {
+				*columnTypes = declaredColumnTypes(declaredTypes)
+			}
+		}
+
+		if rows.Next() {
+			return true
+		}
+	}
+
+	return false
+}
+
+// queryWithRetries runs a query, retrying it with an exponential backoff up to QueryRetries times when it fails
+// with a transient (connection) error. Other errors, like syntax errors, aren't retried
+func (bt *Sqlbeat) queryWithRetries(ctx context.Context, db queryer, index int, queryStr string) (*sql.Rows, error) {
+	backoff := bt.queryRetryBackoff
+
+	for attempt := 1; ; attempt++ {
+		rows, err := db.QueryContext(ctx, queryStr)
+		if err == nil || attempt > bt.queryRetries || !isTransientError(err) || ctx.Err() != nil {
+			return rows, err
+		}
+
+		logp.Warn("Query %v attempt #%d failed with a transient error: %v, retrying in %v %s", bt.queryName(index), attempt, err, backoff, bt.queryLogFields(index, err))
+
+		select {
+		case <-ctx.Done():
+			return nil, err
+		case <-time.After(backoff):
+		}
+
+		// Pinging makes the pool replace its broken connections before the next attempt
+		if pingErr := db.PingContext(ctx); pingErr != nil {
+			logp.Debug("sqlbeat", "Error pinging %v at %v before retrying query %v: %v", bt.dbType, bt.endpoint(), bt.queryName(index), pingErr)
+		}
+
+		// Double the backoff for the next attempt
+		backoff *= 2
+		if backoff > maxConnectRetryBackoff {
+			backoff = maxConnectRetryBackoff
+		}
+	}
+}
+
+// isTransientError returns whether an error is a connection error that may not happen again on a retry
+func isTransientError(err error) bool {
+	if err == driver.ErrBadConn || err == io.EOF || err == io.ErrUnexpectedEOF {
+		return true
+	}
+	_, isNetError := err.(net.Error)
+	return isNetError
+}
+
+// queryTimedOut logs a query timeout, the log level escalates with the number of consecutive timeouts
+// of the query and an alert event is published once QueryTimeoutAlertAfter consecutive timeouts are reached
+func (bt *Sqlbeat) queryTimedOut(b *beat.Beat, index int) {
+	count := bt.timeoutCounts.add(index)
+
+	bt.addQueryError(index)
+
+	if count >= bt.queryTimeoutErrorAfter {
+		logp.Err("Query %v timed out after %v (%d consecutive timeouts) %s", bt.queryName(index), bt.queryTimeouts[index], count,
+			bt.queryLogFields(index, context.DeadlineExceeded))
+	} else if count >= bt.queryTimeoutWarnAfter {
+		logp.Warn("Query %v timed out after %v (%d consecutive timeouts) %s", bt.queryName(index), bt.queryTimeouts[index], count,
+			bt.queryLogFields(index, context.DeadlineExceeded))
+	} else {
+		logp.Debug("sqlbeat", "Query %v timed out after %v (%d consecutive timeouts)", bt.queryName(index), bt.queryTimeouts[index], count)
+	}
+
+	// Alert once per streak of timeouts
+	if bt.queryTimeoutAlertAfter > 0 && count == bt.queryTimeoutAlertAfter {
+		event := common.MapStr{
+			"@timestamp": common.Time(time.Now()),
+			"type":       eventTypeAlert,
+			"sqlbeat": common.MapStr{
+				"alert":                "query_timeout",
+				"query_index":          index,
+				"query":                bt.queryName(index),
+				"consecutive_timeouts": count,
+			},
+		}
+		bt.publish(b, event)
+		logp.Info("%v event sent", eventTypeAlert)
+	}
+}
+
+// checkExpectedColumns compares the columns a query returned to its expected columns, when columns are missing
+// or unexpected an error is logged and an alert event is published. Queries without expected columns aren't checked
+func (bt *Sqlbeat) checkExpectedColumns(b *beat.Beat, index int, columns []string) {
+	if index >= len(bt.queryExpectedColumns) || len(bt.queryExpectedColumns[index]) == 0 {
+		return
+	}
+
+	missing, unexpected := columnsDrift(bt.queryExpectedColumns[index], columns)
+	if len(missing) == 0 && len(unexpected) == 0 {
+		return
+	}
+
+	bt.addQueryError(index)
+	logp.Err("Query %v columns don't match its expected columns, missing: %v, unexpected: %v", bt.queryName(index), missing, unexpected)
+
+	event := common.MapStr{
+		"@timestamp": common.Time(time.Now()),
+		"type":       eventTypeAlert,
+		"sqlbeat": common.MapStr{
+			"alert":              "unexpected_columns",
+			"query_index":        index,
+			"query":              bt.queryName(index),
+			"missing_columns":    missing,
+			"unexpected_columns": unexpected,
+		},
+	}
+	bt.publish(b, event)
+	logp.Info("%v event sent", eventTypeAlert)
+}
+
+// isQueryType returns true if queryType is one of the supported query types
+func isQueryType(queryType string) bool {
+	for _, name := range queryTypeNames {
+		if queryType == name {
+			return true
+		}
+	}
+	return false
+}
+
+// defaultQueryTypeEventTypes are the event types of the query types that are typed by what they report rather than
+// by the DB type
+var defaultQueryTypeEventTypes = map[string]string{
+	queryTypeSlaveDelay:       eventTypeReplication,
+	queryTypeAllSlavesStatus:  eventTypeReplication,
+	queryTypePgReplicationLag: eventTypeReplication,
+}
+
+// eventType returns the type of the events of a query, the query's own event type takes precedence over
+// the event type of its query type in QueryTypeEventTypes, then EventType and the default event type of its query
+// type. The events are typed by the DB type when none is set
+func (bt *Sqlbeat) eventType(index int) string {
+	if index < len(bt.queryEventTypes) && bt.queryEventTypes[index] != "" {
+		return bt.queryEventTypes[index]
+	}
+	var queryType string
+	if index < len(bt.queryTypes) {
+		queryType = bt.queryTypes[index]
+	}
+	if eventType, ok := bt.queryTypeEventTypes[queryType]; ok && eventType != "" {
+		return eventType
+	}
+	if bt.eventTypeOverride != "" {
+		return bt.eventTypeOverride
+	}
+	if eventType, ok := defaultQueryTypeEventTypes[queryType]; ok {
+		// An empty entry in QueryTypeEventTypes opts out of the default
+		if _, disabled := bt.queryTypeEventTypes[queryType]; !disabled {
+			return eventType
+		}
+	}
+	return bt.dbType
+}
+
+// queryName returns the name of a query for logs and events, falling back to its index for unnamed queries
+func (bt *Sqlbeat) queryName(index int) string {
+	if index < len(bt.queryNames) && bt.queryNames[index] != "" {
+		return bt.queryNames[index]
+	}
+	return fmt.Sprintf("#%d", index)
+}
+
+// queryText returns the text of a query for the query_text field, optionally with its string literals
+// redacted and truncated to maxLength characters (0 means the whole query)
+func queryText(queryStr string, maxLength int, redact bool) string {
+	text := strings.TrimSpace(queryStr)
+	if redact {
+		text = stringLiteralRegexp.ReplaceAllString(text, "'?'")
+	}
+
+	if runes := []rune(text); maxLength > 0 && len(runes) > maxLength {
+		text = string(runes[:maxLength]) + "..."
+	}
+	return text
+}
+
+// publishEvents publishes the events of a query in batches of up to BatchSize events as they are received,
+// the last batch is published once the channel is closed
+func (bt *Sqlbeat) publishEvents(b *beat.Beat, index int, events <-chan common.MapStr) {
+	// The events of a publish-on-change query are only published once all were read and compared to the
+	// previous cycle
+	onChangeOnly := index < len(bt.publishOnChangeOnly) && bt.publishOnChangeOnly[index]
+	var cycle []common.MapStr
+
+	// The document ids already used by the events of this cycle
+	var docIDs map[string]bool
+	if bt.docIDColumn != "" {
+		docIDs = make(map[string]bool)
+	}
+
+	batch := make([]common.MapStr, 0, bt.batchSize)
+	for event := range events {
+		if docIDs != nil {
+			bt.setDocID(event, index, docIDs)
+		}
+		if bt.eventLayout == eventLayoutNested {
+			bt.nestEvent(event, index)
+		}
+		if bt.includeQueryName {
+			setEventMeta(event, "query", bt.queryName(index))
+		}
+		if bt.includeQueryText {
+			setEventMeta(event, "query_text", bt.queryTexts[index])
+		}
+		if onChangeOnly {
+			cycle = append(cycle, event)
+			continue
+		}
+
+		batch = append(batch, event)
+		if len(batch) >= bt.batchSize {
+			bt.publishBatch(b, index, batch)
+			// The publisher may hold on to the published batch
+			batch = make([]common.MapStr, 0, bt.batchSize)
+		}
+	}
+
+	if onChangeOnly {
+		hash, err := hashEvents(cycle)
+		if err != nil {
+			logp.Warn("Query %v error hashing the events, publishing them: %v", bt.queryName(index), err)
+		} else if !bt.eventHashes.changed(bt.target+"|"+bt.queries[index], hash) {
+			logp.Debug("sqlbeat", "Query %v events didn't change since the previous period, skipping them", bt.queryName(index))
+			return
+		}
+		for start := 0; start < len(cycle); start += bt.batchSize {
+			end := start + bt.batchSize
+			if end > len(cycle) {
+				end = len(cycle)
+			}
+			bt.publishBatch(b, index, cycle[start:end])
+		}
+		return
+	}
+
+	if len(batch) > 0 {
+		bt.publishBatch(b, index, batch)
+	}
+}
+
+// nestEvent moves the fields of a query event under sqlbeat.<query name> (or sqlbeat.query_<index> for unnamed
+// queries), the timestamp, metadata, type and the sqlbeat fields describing the event stay where they are
+func (bt *Sqlbeat) nestEvent(event common.MapStr, index int) {
+	fields := common.MapStr{}
+	for key, value := range event {
+		if key == "@timestamp" || key == "@metadata" || key == "type" || key == "sqlbeat" {
+			continue
+		}
+		fields[key] = value
+		delete(event, key)
+	}
+	if len(fields) == 0 {
+		return
+	}
+
+	name := fmt.Sprintf("query_%d", index)
+	if index < len(bt.queryNames) && bt.queryNames[index] != "" {
+		name = bt.queryNames[index]
+	}
+	setEventMeta(event, name, fields)
+}
+
+// setEventMeta sets a field of the sqlbeat object of an event, adding the object when the event has none
+func setEventMeta(event common.MapStr, key string, value interface{}) {
+	if meta, ok := event["sqlbeat"].(common.MapStr); ok {
+		meta[key] = value
+	} else {
+		event["sqlbeat"] = common.MapStr{key: value}
+	}
+}
+
+// publishBatch publishes a batch of events of a query
+func (bt *Sqlbeat) publishBatch(b *beat.Beat, index int, batch []common.MapStr) {
+	bt.publish(b, batch...)
+	bt.tick.addEvents(len(batch))
+	logp.Info("%d %v events sent", len(batch), bt.queryTypes[index])
+}
+
+// publishTickEvent publishes the summary of the current tick when PublishTickEvents is enabled
+func (bt *Sqlbeat) publishTickEvent(b *beat.Beat) {
+	// The summary of a tick interrupted by stopping the beat is partial
+	if !bt.publishTickEvents || bt.ctx.Err() != nil {
+		return
+	}
+
+	queries, events, errs := bt.tick.counts()
+	event := common.MapStr{
+		"@timestamp": common.Time(time.Now()),
+		"type":       eventTypeTick,
+		"sqlbeat": common.MapStr{
+			"tick":        bt.tick.number,
+			"queries":     queries,
+			"events":      events,
+			"errors":      errs,
+			"duration_ms": float64(time.Since(bt.tick.start)) / float64(time.Millisecond),
+		},
+	}
+	bt.publish(b, event)
+	logp.Info("%v event sent (tick #%d: %d queries, %d events, %d errors)", eventTypeTick, bt.tick.number, queries, events, errs)
+}
+
+// appendRowToEvent appends the two-column event the current row data
+func (bt *Sqlbeat) appendRowToEvent(event common.MapStr, row *sql.Rows, columns []string, columnTypes []int, queryIndex int, rowAge time.Time) error {
+
+	// Get RawBytes from data
+	values, err := bt.scanRow(row, len(columns))
+	if err != nil {
+		return err
+	}
+
+	// Get the name and the value from their columns (by default the first column is the name, the second is the value)
+	strColName := string(values[bt.twoColumnsNameIndex])
+	strColValue := string(values[bt.twoColumnsValueIndex])
+
+	// Skip the filtered out names
+	if !bt.columnAllowed(queryIndex, strColName) {
+		return nil
+	}
+
+	// Stop adding fields once the event has MaxEventFields fields besides @timestamp and type
+	if bt.maxEventFields > 0 && len(event)-2 >= bt.maxEventFields {
+		return errMaxEventFields
+	}
+
+	// NULL values are replaced by the query's null default when one is configured
+	if values[bt.twoColumnsValueIndex] == nil {
+		if nullValue, ok := bt.nullDefault(queryIndex, strColName); ok {
+			// A null default of null drops the field
+			if nullValue != nil {
+				event[bt.fieldName(strColName)] = nullValue
+			}
+			return nil
+		}
+	}
+
+	// Report the byte length instead of the content
+	if bt.byteLengthColumns[strColName] {
+		event[bt.fieldName(strColName)+"_bytes"] = len(values[bt.twoColumnsValueIndex])
+		return nil
+	}
+
+	// Encode binary values
+	if encoding, ok := bt.binaryColumns[strColName]; ok {
+		setBinaryValue(event, bt.fieldName(strColName), values[bt.twoColumnsValueIndex], encoding)
+		return nil
+	}
+
+	// Convert unix epochs to dates
+	if _, ok := bt.setEpochValue(event, strColName, strColValue); ok {
+		return nil
+	}
 
-	// Make a slice for the values
-	values := make([]sql.RawBytes, len(columns))
+	// Translate the known statuses to their numeric code
+	bt.setStatusCode(event, strColName, strColValue)
 
-	// Copy the references into such a []interface{} for row.Scan
-	scanArgs := make([]interface{}, len(values))
-	for i := range values {
-		scanArgs[i] = &values[i]
+	// Add the value to the event, columns that end with the delta wildcard of the query will report the delta
+	isDelta := bt.isDeltaColumn(queryIndex, strColName)
+	if err := bt.checkDeltaInference(strColName, isDelta); err != nil {
+		return err
+	}
+	columnType := bt.columnType(strColName, columnTypeAt(columnTypes, bt.twoColumnsValueIndex))
+	bt.setTypedColumnValue(event, strColName, strColValue, columnType, isDelta, rowAge)
+	if isDelta {
+		bt.labelDeltaValue(event, queryIndex, strColName)
+		bt.setRawDeltaValue(event, queryIndex, strColName, strColValue, columnType, rowAge)
 	}
 
+	// Great success!
+	return nil
+}
+
+// generateEventFromRow creates a new event from the row data and returns it
+func (bt *Sqlbeat) generateEventFromRow(row *sql.Rows, columns []string, columnTypes []int, queryIndex int, queryType string, rowAge time.Time) (common.MapStr, error) {
+
 	// Create the event and populate it
 	event := common.MapStr{
-		"@timestamp": common.Time(rowAge),
-		"type":       bt.dbType,
+		"@timestamp": bt.eventTime(rowAge),
+		"type":       bt.eventType(queryIndex),
 	}
 
 	// Get RawBytes from data
-	err := row.Scan(scanArgs...)
+	values, err := bt.scanRow(row, len(columns))
 	if err != nil {
 		return nil, err
 	}
@@ -517,101 +3090,104 @@ func (bt *Sqlbeat) generateEventFromRow(row *sql.Rows, columns []string, queryTy
 		// Get column name and string value
 		strColName := string(columns[i])
 		strColValue := string(col)
-		strColType := columnTypeString
 
-		// Skip column proccessing when query type is show-slave-delay and the column isn't Seconds_Behind_Master
-		if queryType == queryTypeSlaveDelay && strColName != columnNameSlaveDelay {
+		// The first column of a time-series row is the time of the row
+		if queryType == queryTypeTimeSeries && i == 0 {
+			timestamp, err := parseTimestamp(strColValue)
+			if err != nil {
+				return nil, fmt.Errorf("The first column of a time-series query must be a timestamp: %v", err)
+			}
+			event["@timestamp"] = bt.eventTime(timestamp)
 			continue
 		}
 
-		// Try to parse the value to an int64
-		nColValue, err := strconv.ParseInt(strColValue, 0, 64)
-		if err == nil {
-			strColType = columnTypeInt
+		// Skip column proccessing when query type is a slave status and the column isn't one of SlaveStatusColumns,
+		// the Connection_name of each slave is always kept to tell the slaves apart
+		isSlaveStatus := queryType == queryTypeSlaveDelay || queryType == queryTypeAllSlavesStatus
+		if isSlaveStatus && !bt.slaveStatusColumns[strColName] &&
+			!(queryType == queryTypeAllSlavesStatus && strColName == columnNameConnectionName) {
+			continue
 		}
 
-		// Try to parse the value to a float64
-		fColValue, err := strconv.ParseFloat(strColValue, 64)
-		if err == nil {
-			// If it's not already an established int64, set type to float
-			if strColType == columnTypeString {
-				strColType = columnTypeFloat
-			}
+		// Skip the filtered out columns
+		if !bt.columnAllowed(queryIndex, strColName) {
+			continue
 		}
 
-		// If query type is single row and the column name ends with the deltaWildcard
-		if queryType == queryTypeSingleRow && strings.HasSuffix(strColName, bt.deltaWildcard) {
-			var exists bool
-			_, exists = bt.oldValues[strColName]
-
-			// If an older value doesn't exist
-			if !exists {
-				// Save the current value in the oldValues array
-				bt.oldValuesAge[strColName] = rowAge
+		// Replication is stopped when Seconds_Behind_Master is NULL
+		if isSlaveStatus && strColName == columnNameSlaveDelay {
+			event["replication_running"] = col != nil
+		}
 
-				if strColType == columnTypeString {
-					bt.oldValues[strColName] = strColValue
-				} else if strColType == columnTypeInt {
-					bt.oldValues[strColName] = nColValue
-				} else if strColType == columnTypeFloat {
-					bt.oldValues[strColName] = fColValue
+		// NULL values are replaced by the query's null default when one is configured
+		if col == nil {
+			if nullValue, ok := bt.nullDefault(queryIndex, strColName); ok {
+				// A null default of null drops the field
+				if nullValue != nil {
+					event[bt.fieldName(strColName)] = nullValue
 				}
+				continue
+			}
+		}
+
+		// A NULL slave delay is reported as SlaveDelayNullValue (null by default)
+		if isSlaveStatus && strColName == columnNameSlaveDelay && col == nil {
+			if bt.slaveDelayNullValue == "" {
+				event[bt.fieldName(strColName)] = nil
 			} else {
-				// If found the old value's age
-				if dtOldAge, ok := bt.oldValuesAge[strColName].(time.Time); ok {
-					delta := rowAge.Sub(dtOldAge)
-
-					if strColType == columnTypeInt {
-						var calcVal int64
-
-						// Get old value
-						oldVal, _ := bt.oldValues[strColName].(int64)
-
-						if nColValue > oldVal {
-							// Calculate the delta
-							devResult := float64((nColValue - oldVal)) / float64(delta.Seconds())
-							// Round the calculated result back to an int64
-							calcVal = roundF2I(devResult, .5)
-						} else {
-							calcVal = 0
-						}
-
-						// Add the delta value to the event
-						event[strColName] = calcVal
-
-						// Save current values as old values
-						bt.oldValues[strColName] = nColValue
-						bt.oldValuesAge[strColName] = rowAge
-					} else if strColType == columnTypeFloat {
-						var calcVal float64
-						oldVal, _ := bt.oldValues[strColName].(float64)
-
-						if fColValue > oldVal {
-							// Calculate the delta
-							calcVal = (fColValue - oldVal) / float64(delta.Seconds())
-						} else {
-							calcVal = 0
-						}
-
-						// Add the delta value to the event
-						event[strColName] = calcVal
-
-						// Save current values as old values
-						bt.oldValues[strColName] = fColValue
-						bt.oldValuesAge[strColName] = rowAge
-					} else {
-						event[strColName] = strColValue
-					}
-				}
+				bt.setColumnValue(event, strColName, bt.slaveDelayNullValue, false, rowAge)
+			}
+			continue
+		}
+
+		// The replication threads states are reported as booleans, only "Yes" means the thread is running
+		if isSlaveStatus && (strColName == columnNameSlaveIORunning || strColName == columnNameSlaveSQLRunning) {
+			event[bt.fieldName(strColName)] = strColValue == "Yes"
+			continue
+		}
+
+		// Report the byte length instead of the content
+		if bt.byteLengthColumns[strColName] {
+			event[bt.fieldName(strColName)+"_bytes"] = len(col)
+			continue
+		}
+
+		// Encode binary values
+		if encoding, ok := bt.binaryColumns[strColName]; ok {
+			setBinaryValue(event, bt.fieldName(strColName), col, encoding)
+			continue
+		}
+
+		// Convert unix epochs to dates, the EpochTimestampColumn is also the time of the event
+		if timestamp, ok := bt.setEpochValue(event, strColName, strColValue); ok {
+			if strColName == bt.epochTimestampColumn {
+				event["@timestamp"] = bt.eventTime(time.Time(timestamp))
 			}
-		} else { // Not a delta column, add the value to the event as is
-			if strColType == columnTypeString {
-				event[strColName] = strColValue
-			} else if strColType == columnTypeInt {
-				event[strColName] = nColValue
-			} else if strColType == columnTypeFloat {
-				event[strColName] = fColValue
+			continue
+		}
+
+		// Nest JSON objects and arrays in the event, other values are handled as usual
+		if bt.jsonColumns[strColName] {
+			if nested, ok := parseJSONColumn(col); ok {
+				event[bt.fieldName(strColName)] = nested
+				continue
 			}
+			logp.Debug("sqlbeat", "Column '%v' isn't a JSON object or array, keeping it as is", strColName)
+		}
+
+		// Translate the known statuses to their numeric code
+		bt.setStatusCode(event, strColName, strColValue)
+
+		// Add the value to the event, delta is only calculated for single row queries
+		isDelta := queryType == queryTypeSingleRow && bt.isDeltaColumn(queryIndex, strColName)
+		if err := bt.checkDeltaInference(strColName, isDelta); err != nil {
+			return nil, err
+		}
+		columnType := bt.columnType(strColName, columnTypeAt(columnTypes, i))
+		bt.setTypedColumnValue(event, strColName, strColValue, columnType, isDelta, rowAge)
+		if isDelta {
+			bt.labelDeltaValue(event, queryIndex, strColName)
+			bt.setRawDeltaValue(event, queryIndex, strColName, strColValue, columnType, rowAge)
 		}
 	}
 
@@ -620,11 +3196,439 @@ func (bt *Sqlbeat) generateEventFromRow(row *sql.Rows, columns []string, queryTy
 		event = nil
 	}
 
+	// The column order of the collected rows is set on their array event, long-format events are split by column
+	if event != nil && bt.includeColumnOrder && queryType != queryTypeLongFormat && !bt.collectsRows(queryIndex) {
+		setEventMeta(event, "columns", bt.columnOrder(queryIndex, columns, event))
+	}
+
 	return event, nil
 }
 
+// setStatusCode adds a <column>_code field with the numeric code of the column's value when the column
+// has a status mapping, values without a code in the mapping don't get the field
+func (bt *Sqlbeat) setStatusCode(event common.MapStr, strColName string, strColValue string) {
+	codes, ok := bt.statusMappings[strColName]
+	if !ok {
+		return
+	}
+
+	code, ok := codes[strColValue]
+	if !ok {
+		logp.Debug("sqlbeat", "Column '%v' has no status code for the value '%v'", strColName, strColValue)
+		return
+	}
+
+	event[bt.fieldName(strColName)+"_code"] = code
+}
+
+// setEpochValue adds the date of an epoch column to the event, ok is false for columns that aren't
+// EpochColumns and for values that aren't an epoch (e.g. NULL), those are handled as usual
+func (bt *Sqlbeat) setEpochValue(event common.MapStr, strColName string, strColValue string) (timestamp common.Time, ok bool) {
+	unit, ok := bt.epochColumns[strColName]
+	if !ok {
+		return timestamp, false
+	}
+
+	epochTime, err := parseEpoch(strColValue, unit)
+	if err != nil {
+		logp.Debug("sqlbeat", "Column '%v' isn't an epoch: %v", strColName, err)
+		return timestamp, false
+	}
+
+	timestamp = common.Time(epochTime)
+	event[bt.fieldName(strColName)] = timestamp
+	return timestamp, true
+}
+
+// setBinaryValue adds a binary value to the event in the given encoding, skipped values aren't added
+func setBinaryValue(event common.MapStr, fieldName string, col []byte, encoding string) {
+	switch encoding {
+	case binaryEncodingHex:
+		event[fieldName] = hex.EncodeToString(col)
+	case binaryEncodingBase64:
+		event[fieldName] = base64.StdEncoding.EncodeToString(col)
+	}
+}
+
+// parseJSONColumn parses a JSON object or array, ok is false for invalid JSON and for other JSON values
+func parseJSONColumn(col []byte) (nested interface{}, ok bool) {
+	err := json.Unmarshal(col, &nested)
+	if err != nil {
+		return nil, false
+	}
+
+	switch nested.(type) {
+	case map[string]interface{}, []interface{}:
+		return nested, true
+	}
+	return nil, false
+}
+
+// columnAllowed returns whether a column passes the include/exclude filters of a query,
+// the query's own filters take precedence over the global ones
+func (bt *Sqlbeat) columnAllowed(queryIndex int, strColName string) bool {
+	includeColumns := bt.includeColumns
+	if queryIndex < len(bt.queryIncludeColumns) && len(bt.queryIncludeColumns[queryIndex]) > 0 {
+		includeColumns = bt.queryIncludeColumns[queryIndex]
+	}
+
+	excludeColumns := bt.excludeColumns
+	if queryIndex < len(bt.queryExcludeColumns) && len(bt.queryExcludeColumns[queryIndex]) > 0 {
+		excludeColumns = bt.queryExcludeColumns[queryIndex]
+	}
+
+	// When include patterns are defined the column must match one of them
+	if len(includeColumns) > 0 && !matchColumn(includeColumns, strColName) {
+		return false
+	}
+
+	return !matchColumn(excludeColumns, strColName)
+}
+
+// matchColumn returns whether a column name matches one of the glob patterns
+func matchColumn(patterns []string, strColName string) bool {
+	for _, pattern := range patterns {
+		if matched, _ := path.Match(pattern, strColName); matched {
+			return true
+		}
+	}
+	return false
+}
+
+// fieldName returns the event field name of a column, renamed columns are used as is
+// while the others are normalized according to FieldNameCase
+func (bt *Sqlbeat) fieldName(strColName string) string {
+	if renamed, ok := bt.columnRenames[strColName]; ok {
+		return renamed
+	}
+
+	switch bt.fieldNameCase {
+	case fieldNameCaseLower:
+		return strings.ToLower(strColName)
+	case fieldNameCaseSnake:
+		return toSnakeCase(strColName)
+	}
+	return strColName
+}
+
+// toSnakeCase converts a column name (PascalCase, camelCase or with spaces) to snake_case
+func toSnakeCase(strColName string) string {
+	runes := []rune(strColName)
+	snake := make([]rune, 0, len(runes)+4)
+
+	for i, r := range runes {
+		// Spaces and dashes become underscores
+		if r == ' ' || r == '-' {
+			r = '_'
+		}
+
+		// An upper case letter starts a new word after a lower case letter or a digit,
+		// or when it's the last letter of an acronym (e.g. HTTPRequests)
+		if unicode.IsUpper(r) && i > 0 && snake[len(snake)-1] != '_' {
+			prev := runes[i-1]
+			nextIsLower := i+1 < len(runes) && unicode.IsLower(runes[i+1])
+			if unicode.IsLower(prev) || unicode.IsDigit(prev) || (unicode.IsUpper(prev) && nextIsLower) {
+				snake = append(snake, '_')
+			}
+		}
+
+		snake = append(snake, unicode.ToLower(r))
+	}
+
+	return string(snake)
+}
+
+// nullDefault returns the value configured to replace a NULL column of a query, ok is false when there is none
+func (bt *Sqlbeat) nullDefault(queryIndex int, strColName string) (value interface{}, ok bool) {
+	if queryIndex >= len(bt.queryNullDefaults) {
+		return nil, false
+	}
+
+	value, ok = bt.queryNullDefaults[queryIndex][strColName]
+	return value, ok
+}
+
+// splitEvent splits an event to one event per field, holding the field name as metric_name and its value as metric_value
+func splitEvent(event common.MapStr) []common.MapStr {
+	names := make([]string, 0, len(event))
+	for name := range event {
+		if name == "@timestamp" || name == "type" {
+			continue
+		}
+		names = append(names, name)
+	}
+
+	// Map iteration order is random, keep the events order stable
+	sort.Strings(names)
+
+	metricEvents := make([]common.MapStr, 0, len(names))
+	for _, name := range names {
+		metricEvents = append(metricEvents, common.MapStr{
+			"@timestamp":   event["@timestamp"],
+			"type":         event["type"],
+			"metric_name":  name,
+			"metric_value": event[name],
+		})
+	}
+
+	return metricEvents
+}
+
+// parseColumnValue tries to parse a column value to an int64, an uint64 and a float64 and returns the detected column type
+func parseColumnValue(strColValue string) (int, int64, uint64, float64) {
+	strColType := columnTypeString
+
+	// Try to parse the value to an int64, always in base 10 so values like "0123" or "0x1F" aren't read as octal or hex
+	nColValue, err := strconv.ParseInt(strColValue, 10, 64)
+	if err == nil {
+		strColType = columnTypeInt
+	}
+
+	// Try to parse the value to an uint64, for unsigned values that overflow int64 (e.g. BIGINT UNSIGNED)
+	uColValue, err := strconv.ParseUint(strColValue, 10, 64)
+	if err == nil {
+		// If it's not already an established int64, set type to uint
+		if strColType == columnTypeString {
+			strColType = columnTypeUint
+		}
+	}
+
+	// Try to parse the value to a float64
+	fColValue, err := strconv.ParseFloat(strColValue, 64)
+	if err == nil {
+		// If it's not already an established int64, set type to float
+		if strColType == columnTypeString {
+			strColType = columnTypeFloat
+		}
+	}
+
+	return strColType, nColValue, uColValue, fColValue
+}
+
+// setColumnValue adds a column to the event, delta columns are reported according to the DeltaOutputMode
+func (bt *Sqlbeat) setColumnValue(event common.MapStr, strColName string, strColValue string, isDelta bool, rowAge time.Time) {
+	bt.setTypedColumnValue(event, strColName, strColValue, columnTypeAuto, isDelta, rowAge)
+}
+
+// setTypedColumnValue is setColumnValue for a value of a known column type, bool values are never delta values
+func (bt *Sqlbeat) setTypedColumnValue(event common.MapStr, strColName string, strColValue string, columnType int, isDelta bool, rowAge time.Time) {
+	fieldName := bt.fieldName(strColName)
+
+	if columnType == columnTypeBool {
+		if bColValue, err := strconv.ParseBool(strColValue); err == nil {
+			event[fieldName] = bColValue
+			return
+		}
+		columnType = columnTypeAuto
+	}
+
+	// Money values formatted as currency (e.g. $1,234.56 from FORMAT(amount, 'C')) are parsed as floats
+	if columnType == columnTypeMoney {
+		strColValue = normalizeMoney(strColValue, bt.numberFormat)
+		columnType = columnTypeFloat
+	}
+
+	// Locale formatted numbers (e.g. from FORMAT()) are parsed like plain numbers. Only text values are normalized,
+	// the columns declared as numbers and the values that already are plain numbers are sent as is
+	if columnType == columnTypeAuto && !isPlainNumber(strColValue) {
+		if normalized, ok := normalizeNumber(strColValue, bt.numberFormat); ok {
+			strColValue = normalized
+		}
+	}
+
+	strColType, nColValue, uColValue, fColValue := parseTypedColumnValue(strColValue, columnType)
+
+	var colValue interface{}
+	if strColType == columnTypeString {
+		colValue = strColValue
+	} else if strColType == columnTypeInt {
+		colValue = nColValue
+	} else if strColType == columnTypeUint {
+		colValue = uColValue
+	} else if strColType == columnTypeFloat {
+		colValue = fColValue
+	}
+
+	// In cumulative mode delta columns report the raw counter
+	if bt.deltaOutputMode == deltaOutputModeCumulative {
+		isDelta = false
+	}
+
+	// Not a delta column, add the value to the event as is
+	if !isDelta {
+		if strColType == columnTypeFloat {
+			event[fieldName] = bt.roundFloat(fColValue)
+		} else {
+			event[fieldName] = colValue
+		}
+		return
+	}
+
+	// Delta columns are stored per target, so targets sharing a column name don't share its old value
+	key := DeltaStateKey(bt.target, strColName)
+	oldValue, dtOldAge, exists := bt.deltaState.get(key)
+	delta := rowAge.Sub(dtOldAge)
+
+	// An old value older than DeltaMaxAge is stale, the current value becomes a fresh baseline
+	if exists && bt.deltaMaxAge > 0 && delta > bt.deltaMaxAge {
+		bt.deltaState.delete(key)
+		exists = false
+	}
+
+	// A rate over a too short interval is meaningless (or infinite), skip the field and keep the old value
+	// so the next delta is calculated over a longer interval
+	if exists && bt.deltaOutputMode == deltaOutputModeRate && (delta < minDeltaInterval || delta < bt.deltaMinInterval) {
+		logp.Debug("sqlbeat", "Skipping delta column '%v', the interval %v is too short", strColName, delta)
+		return
+	}
+
+	// Save current values as old values
+	bt.deltaState.set(key, colValue, rowAge)
+
+	// If an older value doesn't exist there is nothing to calculate yet
+	if !exists {
+		return
+	}
+
+	// The deltas of the first DeltaWarmupCycles cycles after startup only build the baseline
+	if bt.inDeltaWarmup() {
+		return
+	}
+	var rawDelta float64
+
+	// Counters only go up, a lower value means the counter was reset and the delta is 0. Gauges can go down too
+	allowNegative := bt.deltaAllowNegative || bt.deltaNegativeColumns[strColName]
+
+	// Expose the interval the delta was calculated on
+	if bt.includeDeltaInterval && strColType != columnTypeString {
+		event[fieldName+"_interval_seconds"] = delta.Seconds()
+	}
+
+	// Expose the window the delta was calculated on, per column as each column has its own previous sample
+	if bt.includeDeltaTimestamps && strColType != columnTypeString {
+		event[fieldName+"_delta_start"] = common.Time(dtOldAge)
+		event[fieldName+"_delta_end"] = common.Time(rowAge)
+	}
+
+	if strColType == columnTypeInt {
+		var calcVal int64
+
+		// Get old value, an old value above the int64 range was stored as an uint64 so the counter was reset
+		oldVal, _ := oldValue.(int64)
+		_, oldIsUint := oldValue.(uint64)
+		if !oldIsUint && (nColValue > oldVal || allowNegative) {
+			if bt.deltaOutputMode == deltaOutputModeIncrement {
+				// Report the raw difference
+				calcVal = nColValue - oldVal
+			} else {
+				// Calculate the delta
+				devResult := float64((nColValue - oldVal)) / float64(delta.Seconds())
+				// Round the calculated result back to an int64
+				calcVal = roundF2I(devResult, .5)
+			}
+		} else {
+			calcVal = 0
+		}
+
+		// Add the delta value to the event
+		event[fieldName] = calcVal
+		rawDelta = float64(calcVal)
+	} else if strColType == columnTypeUint && allowNegative && uColValue < uintDeltaOldValue(oldValue) {
+		var calcVal int64
+
+		// A decreasing gauge has a negative delta, which only fits an int64
+		oldVal := uintDeltaOldValue(oldValue)
+		if bt.deltaOutputMode == deltaOutputModeIncrement {
+			// Report the raw difference
+			calcVal = -int64(oldVal - uColValue)
+		} else {
+			// Calculate the delta
+			devResult := -float64(oldVal-uColValue) / float64(delta.Seconds())
+			// Round the calculated result back to an int64
+			calcVal = roundF2I(devResult, .5)
+		}
+
+		// Add the delta value to the event
+		event[fieldName] = calcVal
+		rawDelta = float64(calcVal)
+	} else if strColType == columnTypeUint {
+		var calcVal uint64
+
+		// Get old value
+		oldVal := uintDeltaOldValue(oldValue)
+		if uColValue > oldVal {
+			if bt.deltaOutputMode == deltaOutputModeIncrement {
+				// Report the raw difference
+				calcVal = uColValue - oldVal
+			} else {
+				// Calculate the delta
+				devResult := float64((uColValue - oldVal)) / float64(delta.Seconds())
+				// Round the calculated result back to an uint64
+				calcVal = uint64(roundF2I(devResult, .5))
+			}
+		} else {
+			calcVal = 0
+		}
+
+		// Add the delta value to the event
+		event[fieldName] = calcVal
+		rawDelta = float64(calcVal)
+	} else if strColType == columnTypeFloat {
+		var calcVal float64
+
+		// Get old value
+		oldVal, _ := oldValue.(float64)
+		if fColValue > oldVal || allowNegative {
+			// Calculate the delta
+			calcVal = fColValue - oldVal
+			if bt.deltaOutputMode == deltaOutputModeRate {
+				calcVal = calcVal / float64(delta.Seconds())
+			}
+		} else {
+			calcVal = 0
+		}
+
+		// Add the delta value to the event
+		event[fieldName] = bt.roundFloat(calcVal)
+		rawDelta = calcVal
+	} else {
+		event[fieldName] = strColValue
+		return
+	}
+
+	// Add the smoothed delta next to the raw one, an EWMA hides the jitter of the intervals
+	if bt.deltaSmoothingAlpha > 0 {
+		event[fieldName+"_smoothed"] = bt.roundFloat(bt.deltaState.smooth(key, rawDelta, bt.deltaSmoothingAlpha))
+	}
+}
+
+// uintDeltaOldValue returns the old value of a uint delta column, a counter that just crossed the int64 range
+// was stored as an int64
+func uintDeltaOldValue(oldValue interface{}) uint64 {
+	oldVal, ok := oldValue.(uint64)
+	if nOldVal, isInt := oldValue.(int64); !ok && isInt && nOldVal >= 0 {
+		oldVal = uint64(nOldVal)
+	}
+	return oldVal
+}
+
+// roundFloat rounds a float64 to FloatPrecision decimals, a negative precision keeps the full precision
+func (bt *Sqlbeat) roundFloat(val float64) float64 {
+	if bt.floatPrecision < 0 {
+		return val
+	}
+
+	pow := math.Pow(10, float64(bt.floatPrecision))
+	return math.Round(val*pow) / pow
+}
+
 // roundF2I is a function that returns a rounded int64 from a float64
 func roundF2I(val float64, roundOn float64) (newVal int64) {
+	// Round negative values away from zero like positive values
+	if val < 0 {
+		return -roundF2I(-val, roundOn)
+	}
+
 	var round float64
 
 	digit := val
@@ -0,0 +1,56 @@
+package beater
+
+import (
+	"sync"
+	"time"
+)
+
+// tickStats holds the counters of a single tick (period), it's safe for concurrent use
+type tickStats struct {
+	mutex   sync.Mutex
+	number  int
+	start   time.Time
+	queries int
+	events  int
+	errors  int
+}
+
+// newTickStats creates the counters of a tick starting now
+func newTickStats(number int) *tickStats {
+	return &tickStats{
+		number: number,
+		start:  time.Now(),
+	}
+}
+
+// addQuery counts a query that ran in the tick
+func (ts *tickStats) addQuery() {
+	ts.mutex.Lock()
+	defer ts.mutex.Unlock()
+
+	ts.queries++
+}
+
+// addEvents counts events published in the tick
+func (ts *tickStats) addEvents(count int) {
+	ts.mutex.Lock()
+	defer ts.mutex.Unlock()
+
+	ts.events += count
+}
+
+// addError counts an error that occurred in the tick
+func (ts *tickStats) addError() {
+	ts.mutex.Lock()
+	defer ts.mutex.Unlock()
+
+	ts.errors++
+}
+
+// counts returns the number of queries, events and errors of the tick so far
+func (ts *tickStats) counts() (queries int, events int, errs int) {
+	ts.mutex.Lock()
+	defer ts.mutex.Unlock()
+
+	return ts.queries, ts.events, ts.errors
+}
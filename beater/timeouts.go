@@ -0,0 +1,35 @@
+package beater
+
+import (
+	"sync"
+)
+
+// timeoutCounts counts the consecutive timeouts of each query, it's safe for concurrent use
+type timeoutCounts struct {
+	mutex  sync.Mutex
+	counts map[int]int
+}
+
+// newTimeoutCounts creates the counts with no timeouts
+func newTimeoutCounts() *timeoutCounts {
+	return &timeoutCounts{
+		counts: make(map[int]int),
+	}
+}
+
+// add counts a timeout of a query and returns its number of consecutive timeouts
+func (tc *timeoutCounts) add(index int) int {
+	tc.mutex.Lock()
+	defer tc.mutex.Unlock()
+
+	tc.counts[index]++
+	return tc.counts[index]
+}
+
+// reset clears the consecutive timeouts of a query that completed in time
+func (tc *timeoutCounts) reset(index int) {
+	tc.mutex.Lock()
+	defer tc.mutex.Unlock()
+
+	delete(tc.counts, index)
+}
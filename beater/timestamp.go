@@ -0,0 +1,53 @@
+package beater
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// timestampLayouts are the layouts timestamp columns are parsed with, in order
+var timestampLayouts = []string{
+	time.RFC3339Nano,
+	"2006-01-02 15:04:05.999999999Z07:00",
+	"2006-01-02 15:04:05.999999999 -0700 MST",
+	"2006-01-02 15:04:05.999999999",
+	"2006-01-02T15:04:05.999999999",
+	"2006-01-02",
+}
+
+// parseTimestamp parses a timestamp column, either in one of the timestampLayouts (UTC when the layout has no zone)
+// or as a unix epoch in seconds or milliseconds, told apart by their magnitude
+func parseTimestamp(strColValue string) (time.Time, error) {
+	if epoch, err := strconv.ParseInt(strColValue, 10, 64); err == nil {
+		// 1e11 seconds is in the year 5138, a larger epoch is in milliseconds
+		if epoch >= 1e11 || epoch <= -1e11 {
+			return time.Unix(0, epoch*int64(time.Millisecond)), nil
+		}
+		return time.Unix(epoch, 0), nil
+	}
+
+	for _, layout := range timestampLayouts {
+		if timestamp, err := time.Parse(layout, strColValue); err == nil {
+			return timestamp, nil
+		}
+	}
+
+	return time.Time{}, fmt.Errorf("'%v' isn't a supported timestamp", strColValue)
+}
+
+// parseEpoch parses a unix epoch in the given unit
+func parseEpoch(strColValue string, unit string) (time.Time, error) {
+	epoch, err := strconv.ParseInt(strColValue, 10, 64)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("'%v' isn't an integer", strColValue)
+	}
+
+	switch unit {
+	case epochUnitMillis:
+		return time.Unix(0, epoch*int64(time.Millisecond)), nil
+	case epochUnitMicros:
+		return time.Unix(0, epoch*int64(time.Microsecond)), nil
+	}
+	return time.Unix(epoch, 0), nil
+}
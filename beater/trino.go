@@ -0,0 +1,81 @@
+package beater
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+
+	_ "github.com/trinodb/trino-go-client/trino"
+)
+
+// scanRow scans the current row as text, the typed values of Trino (including its arrays, maps and rows)
+// can't be scanned into RawBytes and are converted by scanTypedRow
+func (bt *Sqlbeat) scanRow(row *sql.Rows, count int) ([]sql.RawBytes, error) {
+	if bt.dbType == dbtTrino {
+		return scanTypedRow(row, count)
+	}
+
+	// Make a slice for the values
+	values := make([]sql.RawBytes, count)
+
+	// Copy the references into such a []interface{} for row.Scan
+	scanArgs := make([]interface{}, len(values))
+	for i := range values {
+		scanArgs[i] = &values[i]
+	}
+
+	// Get RawBytes from data
+	err := row.Scan(scanArgs...)
+	if err != nil {
+		return nil, err
+	}
+	return values, nil
+}
+
+// scanTypedRow scans the current row as typed values and converts them to the text RawBytes would hold
+func scanTypedRow(row *sql.Rows, count int) ([]sql.RawBytes, error) {
+	typedValues := make([]interface{}, count)
+	scanArgs := make([]interface{}, count)
+	for i := range typedValues {
+		scanArgs[i] = &typedValues[i]
+	}
+
+	err := row.Scan(scanArgs...)
+	if err != nil {
+		return nil, err
+	}
+
+	values := make([]sql.RawBytes, count)
+	for i, value := range typedValues {
+		values[i], err = typedValueBytes(value)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return values, nil
+}
+
+// typedValueBytes returns the text of a typed value (nil for NULL), structured values are sent as JSON
+func typedValueBytes(value interface{}) ([]byte, error) {
+	switch v := value.(type) {
+	case nil:
+		return nil, nil
+	case []byte:
+		return v, nil
+	case string:
+		return []byte(v), nil
+	case bool:
+		return []byte(strconv.FormatBool(v)), nil
+	case int64:
+		return []byte(strconv.FormatInt(v, 10)), nil
+	case float64:
+		return []byte(strconv.FormatFloat(v, 'f', -1, 64)), nil
+	case time.Time:
+		return []byte(v.Format(time.RFC3339Nano)), nil
+	case []interface{}, map[string]interface{}:
+		return json.Marshal(v)
+	}
+	return []byte(fmt.Sprint(value)), nil
+}
@@ -0,0 +1,173 @@
+package beater
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/elastic/beats/libbeat/logp"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+// sshTunnel forwards the connections to a local port to the DB through an SSH server, it's safe for concurrent use.
+// The local port stays the same for the life of the tunnel so the connection string doesn't change when the SSH
+// connection is reconnected
+type sshTunnel struct {
+	mutex      sync.Mutex
+	sshAddr    string
+	config     *ssh.ClientConfig
+	remoteAddr string
+	client     *ssh.Client
+	listener   net.Listener
+}
+
+// newSSHClientConfig builds the SSH client config of a tunnel from a private key file, the host key of the SSH
+// server is verified against a known_hosts file
+func newSSHClientConfig(user string, keyFile string, knownHostsFile string) (*ssh.ClientConfig, error) {
+	key, err := ioutil.ReadFile(expandHome(keyFile))
+	if err != nil {
+		return nil, fmt.Errorf("Error reading SSHKeyFile: %v", err)
+	}
+	signer, err := ssh.ParsePrivateKey(key)
+	if err != nil {
+		return nil, fmt.Errorf("Error parsing SSHKeyFile: %v", err)
+	}
+
+	hostKeyCallback, err := knownhosts.New(expandHome(knownHostsFile))
+	if err != nil {
+		return nil, fmt.Errorf("Error reading SSHKnownHostsFile: %v", err)
+	}
+
+	return &ssh.ClientConfig{
+		User:            user,
+		Auth:            []ssh.AuthMethod{ssh.PublicKeys(signer)},
+		HostKeyCallback: hostKeyCallback,
+		Timeout:         checkCredentialsTimeout,
+	}, nil
+}
+
+// newSSHTunnel listens on a local port and forwards its connections through the SSH server
+func newSSHTunnel(sshAddr string, config *ssh.ClientConfig) (*sshTunnel, error) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, fmt.Errorf("Error listening for the SSH tunnel: %v", err)
+	}
+
+	tunnel := &sshTunnel{
+		sshAddr:  sshAddr,
+		config:   config,
+		listener: listener,
+	}
+	go tunnel.serve()
+
+	logp.Info("SSH tunnel through %v listening on %v", sshAddr, listener.Addr())
+	return tunnel, nil
+}
+
+// localAddr returns the host and port the DB connections should connect to
+func (t *sshTunnel) localAddr() (string, string) {
+	host, port, _ := net.SplitHostPort(t.listener.Addr().String())
+	return host, port
+}
+
+// setRemote sets the DB address the new connections are forwarded to (e.g. after a failover)
+func (t *sshTunnel) setRemote(remoteAddr string) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	t.remoteAddr = remoteAddr
+}
+
+// serve forwards the local connections until the tunnel is closed
+func (t *sshTunnel) serve() {
+	for {
+		local, err := t.listener.Accept()
+		if err != nil {
+			return
+		}
+		go t.forward(local)
+	}
+}
+
+// forward pipes a local connection to the DB through the SSH server
+func (t *sshTunnel) forward(local net.Conn) {
+	defer local.Close()
+
+	remote, err := t.dialRemote()
+	if err != nil {
+		logp.Err("SSH tunnel error: %v", err)
+		return
+	}
+	defer remote.Close()
+
+	done := make(chan struct{}, 2)
+	go func() {
+		io.Copy(remote, local)
+		done <- struct{}{}
+	}()
+	go func() {
+		io.Copy(local, remote)
+		done <- struct{}{}
+	}()
+	<-done
+}
+
+// dialRemote dials the DB through the SSH server, the SSH connection is reconnected once when it's broken
+func (t *sshTunnel) dialRemote() (net.Conn, error) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	var err error
+	for attempt := 1; attempt <= 2; attempt++ {
+		if t.client == nil {
+			t.client, err = ssh.Dial("tcp", t.sshAddr, t.config)
+			if err != nil {
+				return nil, fmt.Errorf("Error connecting to SSH server %v: %v", t.sshAddr, err)
+			}
+		}
+
+		var remote net.Conn
+		remote, err = t.client.Dial("tcp", t.remoteAddr)
+		if err == nil {
+			return remote, nil
+		}
+
+		// The SSH connection may have gone away, reconnect it and try again
+		logp.Warn("Error dialing %v through SSH server %v, reconnecting: %v", t.remoteAddr, t.sshAddr, err)
+		t.client.Close()
+		t.client = nil
+	}
+
+	return nil, fmt.Errorf("Error dialing %v through SSH server %v: %v", t.remoteAddr, t.sshAddr, err)
+}
+
+// close stops listening and closes the SSH connection, the forwarded connections are closed with it
+func (t *sshTunnel) close() {
+	t.listener.Close()
+
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	if t.client != nil {
+		t.client.Close()
+		t.client = nil
+	}
+}
+
+// expandHome expands a leading ~ to the home directory of the user
+func expandHome(path string) string {
+	if path != "~" && !strings.HasPrefix(path, "~/") {
+		return path
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return path
+	}
+	return filepath.Join(home, path[1:])
+}
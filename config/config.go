@@ -8,16 +8,149 @@ type Config struct {
 }
 
 type SqlbeatConfig struct {
-	Period            string   `yaml:"period"`
-	DBType            string   `yaml:"dbtype"`
-	Hostname          string   `yaml:"hostname"`
-	Port              string   `yaml:"port"`
-	Username          string   `yaml:"username"`
-	Password          string   `yaml:"password"`
-	EncryptedPassword string   `yaml:"encryptedpassword"`
-	Database          string   `yaml:"database"`
-	PostgresSSLMode   string   `yaml:"postgressslmode"`
-	Queries           []string `yaml:"queries"`
-	QueryTypes        []string `yaml:"querytypes"`
-	DeltaWildcard     string   `yaml:"deltawildcard"`
+	Period                  string                    `yaml:"period"`
+	DBType                  string                    `yaml:"dbtype"`
+	ConnString              string                    `yaml:"connstring"`
+	ApplicationName         string                    `yaml:"applicationname"`
+	Hostname                string                    `yaml:"hostname"`
+	Hostnames               []string                  `yaml:"hostnames"`
+	Port                    string                    `yaml:"port"`
+	Username                string                    `yaml:"username"`
+	Password                string                    `yaml:"password"`
+	EncryptedPassword       string                    `yaml:"encryptedpassword"`
+	EncryptionMode          string                    `yaml:"encryptionmode"`
+	AuthMode                string                    `yaml:"authmode"`
+	AWSRegion               string                    `yaml:"awsregion"`
+	SSHHost                 string                    `yaml:"sshhost"`
+	SSHUser                 string                    `yaml:"sshuser"`
+	SSHKeyFile              string                    `yaml:"sshkeyfile"`
+	SSHKnownHostsFile       string                    `yaml:"sshknownhostsfile"`
+	CheckCredentials        bool                      `yaml:"checkcredentials"`
+	Database                string                    `yaml:"database"`
+	DSNParams               map[string]string         `yaml:"dsnparams"`
+	MySQLTimeout            string                    `yaml:"mysqltimeout"`
+	MySQLReadTimeout        string                    `yaml:"mysqlreadtimeout"`
+	MySQLWriteTimeout       string                    `yaml:"mysqlwritetimeout"`
+	MySQLInterpolateParams  bool                      `yaml:"mysqlinterpolateparams"`
+	PostgresSSLMode         string                    `yaml:"postgressslmode"`
+	PostgresSSLCert         string                    `yaml:"postgressslcert"`
+	PostgresSSLKey          string                    `yaml:"postgressslkey"`
+	PostgresSSLRootCert     string                    `yaml:"postgressslrootcert"`
+	BigQueryProject         string                    `yaml:"bigqueryproject"`
+	BigQueryDataset         string                    `yaml:"bigquerydataset"`
+	BigQueryLocation        string                    `yaml:"bigquerylocation"`
+	BigQueryCredentialsFile string                    `yaml:"bigquerycredentialsfile"`
+	TrinoCatalog            string                    `yaml:"trinocatalog"`
+	TrinoHTTPS              bool                      `yaml:"trinohttps"`
+	Queries                 []string                  `yaml:"queries"`
+	QueryTypes              []string                  `yaml:"querytypes"`
+	QueryNames              []string                  `yaml:"querynames"`
+	IncludeQueryName        bool                      `yaml:"includequeryname"`
+	IncludeQueryText        bool                      `yaml:"includequerytext"`
+	IncludeColumnOrder      bool                      `yaml:"includecolumnorder"`
+	QueryTextMaxLength      int                       `yaml:"querytextmaxlength"`
+	RedactQueryText         bool                      `yaml:"redactquerytext"`
+	EventType               string                    `yaml:"eventtype"`
+	QueryEventTypes         []string                  `yaml:"queryeventtypes"`
+	QueryTypeEventTypes     map[string]string         `yaml:"querytypeeventtypes"`
+	QueryCatalog            string                    `yaml:"querycatalog"`
+	SlaveDelayNullValue     string                    `yaml:"slavedelaynullvalue"`
+	SlaveStatusColumns      []string                  `yaml:"slavestatuscolumns"`
+	MultiRowMode            string                    `yaml:"multirowmode"`
+	MultiRowArrayKey        string                    `yaml:"multirowarraykey"`
+	EventLayout             string                    `yaml:"eventlayout"`
+	ColumnRenames           map[string]string         `yaml:"columnrenames"`
+	FieldNameCase           string                    `yaml:"fieldnamecase"`
+	ByteLengthColumns       []string                  `yaml:"bytelengthcolumns"`
+	JSONColumns             []string                  `yaml:"jsoncolumns"`
+	BinaryColumns           map[string]string         `yaml:"binarycolumns"`
+	EpochColumns            map[string]string         `yaml:"epochcolumns"`
+	EpochTimestampColumn    string                    `yaml:"epochtimestampcolumn"`
+	TimestampTruncate       string                    `yaml:"timestamptruncate"`
+	StatusMappings          map[string]map[string]int `yaml:"statusmappings"`
+	TwoColumnsNameIndex     int                       `yaml:"twocolumnsnameindex"`
+	TwoColumnsValueIndex    *int                      `yaml:"twocolumnsvalueindex"`
+	QueryNullDefaults       []map[string]interface{}  `yaml:"querynulldefaults"`
+	IncludeColumns          []string                  `yaml:"includecolumns"`
+	ExcludeColumns          []string                  `yaml:"excludecolumns"`
+	DuplicateColumnPolicy   string                    `yaml:"duplicatecolumnpolicy"`
+	QueryIncludeColumns     [][]string                `yaml:"queryincludecolumns"`
+	QueryExcludeColumns     [][]string                `yaml:"queryexcludecolumns"`
+	QueryExpectedColumns    [][]string                `yaml:"queryexpectedcolumns"`
+	QueryTimeouts           []string                  `yaml:"querytimeouts"`
+	QueryTimeoutWarnAfter   int                       `yaml:"querytimeoutwarnafter"`
+	QueryTimeoutErrorAfter  int                       `yaml:"querytimeouterrorafter"`
+	QueryTimeoutAlertAfter  int                       `yaml:"querytimeoutalertafter"`
+	DeltaWildcard           string                    `yaml:"deltawildcard"`
+	QueryDeltaWildcards     []string                  `yaml:"querydeltawildcards"`
+	EmitRawWithDelta        bool                      `yaml:"emitrawwithdelta"`
+	DeltaFieldSuffix        string                    `yaml:"deltafieldsuffix"`
+	IncludeDeltaUnit        bool                      `yaml:"includedeltaunit"`
+	DeltaOutputMode         string                    `yaml:"deltaoutputmode"`
+	IncludeDeltaInterval    bool                      `yaml:"includedeltainterval"`
+	IncludeDeltaTimestamps  bool                      `yaml:"includedeltatimestamps"`
+	DeltaSmoothingAlpha     float64                   `yaml:"deltasmoothingalpha"`
+	DeltaMinInterval        string                    `yaml:"deltamininterval"`
+	DeltaMaxAge             string                    `yaml:"deltamaxage"`
+	DeltaWarmupCycles       int                       `yaml:"deltawarmupcycles"`
+	DeltaAllowNegative      bool                      `yaml:"deltaallownegative"`
+	DeltaNegativeColumns    []string                  `yaml:"deltanegativecolumns"`
+	ResetDeltaOnReload      bool                      `yaml:"resetdeltaonreload"`
+	FloatPrecision          *int                      `yaml:"floatprecision"`
+	NumberFormat            string                    `yaml:"numberformat"`
+	DisableTypeInference    bool                      `yaml:"disabletypeinference"`
+	ConnectRetries          int                       `yaml:"connectretries"`
+	ConnectRetryBackoff     string                    `yaml:"connectretrybackoff"`
+	QueryRetries            int                       `yaml:"queryretries"`
+	QueryRetryBackoff       string                    `yaml:"queryretrybackoff"`
+	Concurrency             int                       `yaml:"concurrency"`
+	ConnectionPools         map[string]int            `yaml:"connectionpools"`
+	QueryPools              []string                  `yaml:"querypools"`
+	QueryDatabases          []string                  `yaml:"querydatabases"`
+	QueryOutputParams       []map[string]string       `yaml:"queryoutputparams"`
+	QueryKeyColumns         []string                  `yaml:"querykeycolumns"`
+	PublishOnChangeOnly     []bool                    `yaml:"publishonchangeonly"`
+	DocIDColumn             string                    `yaml:"docidcolumn"`
+	InitQueries             []string                  `yaml:"initqueries"`
+	ResultBufferSize        int                       `yaml:"resultbuffersize"`
+	BatchSize               int                       `yaml:"batchsize"`
+	MaxRowsPerQuery         int                       `yaml:"maxrowsperquery"`
+	MaxConsecutiveRowErrors int                       `yaml:"maxconsecutiverowerrors"`
+	MaxEventFields          int                       `yaml:"maxeventfields"`
+	UseColumnTypes          bool                      `yaml:"usecolumntypes"`
+	MultipleResultSets      bool                      `yaml:"multipleresultsets"`
+	ColumnTypes             map[string]string         `yaml:"columntypes"`
+	ShardIndex              int                       `yaml:"shardindex"`
+	ShardTotal              int                       `yaml:"shardtotal"`
+	TargetName              string                    `yaml:"targetname"`
+	TargetTags              []string                  `yaml:"targettags"`
+	QueryTargets            [][]string                `yaml:"querytargets"`
+	PublishEmptyEvents      bool                      `yaml:"publishemptyevents"`
+	PublishQueryMetrics     bool                      `yaml:"publishquerymetrics"`
+	IncludeQueryDiagnostics bool                      `yaml:"includequerydiagnostics"`
+	PublishTickEvents       bool                      `yaml:"publishtickevents"`
+	MetricsAddr             string                    `yaml:"metricsaddr"`
+	HealthAddr              string                    `yaml:"healthaddr"`
+	HealthStaleAfter        string                    `yaml:"healthstaleafter"`
+	DebugOutputFile         string                    `yaml:"debugoutputfile"`
+	DebugOutputOnly         bool                      `yaml:"debugoutputonly"`
+}
+
+// QueryCatalogEntry is a fully specified query loaded from a query catalog file
+type QueryCatalogEntry struct {
+	Query               string                 `yaml:"query" json:"query"`
+	Type                string                 `yaml:"type" json:"type"`
+	Timeout             string                 `yaml:"timeout" json:"timeout"`
+	NullDefaults        map[string]interface{} `yaml:"nulldefaults" json:"nulldefaults"`
+	IncludeColumns      []string               `yaml:"includecolumns" json:"includecolumns"`
+	ExcludeColumns      []string               `yaml:"excludecolumns" json:"excludecolumns"`
+	ExpectedColumns     []string               `yaml:"expectedcolumns" json:"expectedcolumns"`
+	Pool                string                 `yaml:"pool" json:"pool"`
+	Name                string                 `yaml:"name" json:"name"`
+	EventType           string                 `yaml:"eventtype" json:"eventtype"`
+	Database            string                 `yaml:"database" json:"database"`
+	KeyColumn           string                 `yaml:"keycolumn" json:"keycolumn"`
+	DeltaWildcard       string                 `yaml:"deltawildcard" json:"deltawildcard"`
+	Targets             []string               `yaml:"targets" json:"targets"`
+	PublishOnChangeOnly bool                   `yaml:"publishonchangeonly" json:"publishonchangeonly"`
 }
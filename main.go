@@ -1,16 +1,70 @@
 package main
 
 import (
+	"bufio"
+	"flag"
+	"fmt"
 	"os"
+	"strings"
 
 	"github.com/elastic/beats/libbeat/beat"
 
 	"github.com/adibendahan/sqlbeat/beater"
 )
 
+var validateQueries = flag.Bool("validate-queries", false, "Validate the syntax of the queries against the DB without running them, then exit")
+
+// queryValidator runs the beat, or with -validate-queries validates the queries against the DB instead of running them
+type queryValidator struct {
+	*beater.Sqlbeat
+}
+
+// Run runs the beat, in validate mode the beat exits once the queries are checked
+func (qv queryValidator) Run(b *beat.Beat) error {
+	if !*validateQueries {
+		return qv.Sqlbeat.Run(b)
+	}
+	return qv.ValidateQueries()
+}
+
 func main() {
-	err := beat.Run("sqlbeat", "", beater.New())
+	// `sqlbeat encrypt-password [cfb|gcm]` encrypts a password read from stdin with the compiled secret
+	if len(os.Args) > 1 && os.Args[1] == "encrypt-password" {
+		mode := "cfb"
+		if len(os.Args) > 2 {
+			mode = os.Args[2]
+		}
+		err := encryptPassword(mode)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	// The flags are parsed by beat.Run, so the validator checks -validate-queries once the beat is set up
+	err := beat.Run("sqlbeat", "", queryValidator{beater.New()})
 	if err != nil {
 		os.Exit(1)
 	}
+	if *validateQueries {
+		fmt.Println("All queries are valid")
+	}
+}
+
+// encryptPassword reads a plaintext password from the first line of stdin and prints it encrypted
+func encryptPassword(mode string) error {
+	password, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil && password == "" {
+		return fmt.Errorf("Error reading the password from stdin: %v", err)
+	}
+	password = strings.TrimRight(password, "\r\n")
+
+	encryptedPassword, err := beater.EncryptPassword(password, mode)
+	if err != nil {
+		return fmt.Errorf("Error encrypting the password: %v", err)
+	}
+
+	fmt.Println(encryptedPassword)
+	return nil
 }